@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// unreadBody falha o teste se o handler tentar ler o corpo
+type unreadBody struct{ t *testing.T }
+
+func (b unreadBody) Read([]byte) (int, error) {
+	b.t.Error("body was read despite the declared Content-Length")
+	return 0, io.EOF
+}
+
+func withMaxPaymentBody(t *testing.T, n int64) {
+	t.Helper()
+	prev := maxPaymentBodyBytes
+	maxPaymentBodyBytes = n
+	t.Cleanup(func() { maxPaymentBodyBytes = prev })
+}
+
+func TestHandlePaymentsRejectsDeclaredOversize(t *testing.T) {
+	withMaxPaymentBody(t, 64)
+	g := newTestGateway(t, false)
+
+	req := httptest.NewRequest("POST", "/payments", io.NopCloser(unreadBody{t}))
+	req.ContentLength = 65
+	rec := httptest.NewRecorder()
+	g.handlePayments(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", rec.Code)
+	}
+}
+
+func TestHandlePaymentsRejectsChunkedOversize(t *testing.T) {
+	withMaxPaymentBody(t, 64)
+	var calls atomic.Int64
+	withOrchestrator(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	})
+	g := newTestGateway(t, false)
+
+	// Sem Content-Length (chunked): só o MaxBytesReader pega o excesso
+	body := `{"correlationId":"big","amount":1,"description":"` + strings.Repeat("x", 128) + `"}`
+	req := httptest.NewRequest("POST", "/payments", io.NopCloser(strings.NewReader(body)))
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	g.handlePayments(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", rec.Code)
+	}
+	if calls.Load() != 0 {
+		t.Fatal("oversized payment reached the orchestrator")
+	}
+}
+
+func TestHandlePaymentsAcceptsBodyWithinLimit(t *testing.T) {
+	withMaxPaymentBody(t, 128)
+	withOrchestrator(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"ok","status":"processed","message":"ok","processor":"default"}`))
+	})
+	g := newTestGateway(t, false)
+
+	if rec := postPayment(g, `{"correlationId":"ok","amount":1}`); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOrchestratorFailuresTripGatewayBreaker(t *testing.T) {
+	var calls atomic.Int64
+	withOrchestrator(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	g := newTestGateway(t, false)
+
+	for i := 0; i < circuitBreaker.threshold; i++ {
+		if rec := postPayment(g, fmt.Sprintf(`{"correlationId":"fail-%d","amount":1}`, i)); rec.Code != http.StatusBadGateway {
+			t.Fatalf("request %d: status = %d, want 502", i, rec.Code)
+		}
+	}
+	if state := circuitBreaker.currentState(); state != OPEN {
+		t.Fatalf("breaker state = %s, want OPEN", state)
+	}
+
+	before := calls.Load()
+	rec := postPayment(g, `{"correlationId":"blocked","amount":1}`)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status with open breaker = %d, want 503", rec.Code)
+	}
+	if calls.Load() != before {
+		t.Fatal("open breaker still called the orchestrator")
+	}
+}
+
+func TestOrchestratorClientErrorsDoNotTripBreaker(t *testing.T) {
+	withOrchestrator(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	g := newTestGateway(t, false)
+
+	for i := 0; i < circuitBreaker.threshold+2; i++ {
+		if rec := postPayment(g, fmt.Sprintf(`{"correlationId":"cap-%d","amount":1}`, i)); rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("request %d: status = %d, want 429 passed through", i, rec.Code)
+		}
+	}
+	if state := circuitBreaker.currentState(); state != CLOSED {
+		t.Fatalf("breaker state = %s, want CLOSED", state)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func withCorrelationIDMode(t *testing.T, mode string) {
+	t.Helper()
+	prev := correlationIDMode
+	correlationIDMode = mode
+	t.Cleanup(func() { correlationIDMode = prev })
+}
+
+func TestStrictModeRejectsMissingCorrelationID(t *testing.T) {
+	withCorrelationIDMode(t, "strict")
+	var calls atomic.Int64
+	withOrchestrator(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	})
+	g := newTestGateway(t, false)
+
+	for _, body := range []string{`{"amount":1}`, `{"correlationId":"","amount":1}`, `{"correlationId":null,"amount":1}`} {
+		if rec := postPayment(g, body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+	if calls.Load() != 0 {
+		t.Fatal("payment without correlationId reached the orchestrator")
+	}
+}
+
+func TestLenientModeGeneratesCorrelationID(t *testing.T) {
+	withCorrelationIDMode(t, "lenient")
+	var mu sync.Mutex
+	var forwarded []string
+	withOrchestrator(t, func(w http.ResponseWriter, r *http.Request) {
+		var req PaymentRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		forwarded = append(forwarded, req.CorrelationID)
+		mu.Unlock()
+		w.Write([]byte(`{"id":"` + req.CorrelationID + `","status":"processed","message":"ok","processor":"default"}`))
+	})
+	g := newTestGateway(t, false)
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	ids := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		rec := postPayment(g, `{"amount":1}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (%s)", rec.Code, rec.Body)
+		}
+		var resp HTTPPaymentResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if !uuid.MatchString(resp.ID) {
+			t.Fatalf("generated id %q is not a UUID v4", resp.ID)
+		}
+		if loc := rec.Header().Get("Location"); loc != "/payments/"+resp.ID {
+			t.Fatalf("Location = %q, want /payments/%s", loc, resp.ID)
+		}
+		ids[resp.ID] = true
+	}
+	// Cada pagamento sem ID ganha o seu: nada colapsa numa chave vazia
+	if len(ids) != 2 {
+		t.Fatalf("generated ids = %v, want two distinct", ids)
+	}
+	for _, id := range forwarded {
+		if !ids[id] {
+			t.Fatalf("orchestrator got correlationId %q, not the generated one", id)
+		}
+	}
+
+	// Com correlationId informado, o modo lenient não mexe nele nem no Location
+	rec := postPayment(g, `{"correlationId":"given","amount":1}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("Location") != "" {
+		t.Fatalf("status = %d, Location = %q; want 200 without Location", rec.Code, rec.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/dedup"
+)
+
+// deadDeduper simula um backend de dedup fora do ar (ex.: Redis caído)
+type deadDeduper struct{}
+
+var errDedupDown = errors.New("dedup backend down")
+
+func (deadDeduper) Seen(string) (bool, error) { return false, errDedupDown }
+func (deadDeduper) Mark(string) error         { return errDedupDown }
+func (deadDeduper) Len() (int, error)         { return 0, errDedupDown }
+func (deadDeduper) Clear() error              { return errDedupDown }
+func (deadDeduper) Close() error              { return nil }
+
+func TestDeadDedupPolicies(t *testing.T) {
+	cases := []struct {
+		policy    string
+		wantCode  int
+		wantCalls int64
+	}{
+		// Idempotência acima de tudo: sem dedup, nada é cobrado
+		{"fail-closed", http.StatusServiceUnavailable, 0},
+		// Disponibilidade: segue para o orchestrator aceitando possíveis duplicatas
+		{"fail-open", http.StatusOK, 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.policy, func(t *testing.T) {
+			prev := dedup.SetFailurePolicy(tc.policy)
+			t.Cleanup(func() { dedup.SetFailurePolicy(prev) })
+			var calls atomic.Int64
+			withOrchestrator(t, func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.Write([]byte(`{"id":"d-1","status":"processed","message":"ok","processor":"default"}`))
+			})
+			g := newTestGateway(t, false)
+			g.deduper = deadDeduper{}
+
+			rec := postPayment(g, `{"correlationId":"d-1","amount":1}`)
+			if rec.Code != tc.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tc.wantCode, rec.Body)
+			}
+			if calls.Load() != tc.wantCalls {
+				t.Fatalf("orchestrator calls = %d, want %d", calls.Load(), tc.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
+)
+
+var (
+	// EARLY_ACK: grava o pagamento como pendente e responde 202 antes de
+	// chamar o orchestrator; o processamento acontece em background
+	earlyAck = config.GetBool("EARLY_ACK", false)
+
+	earlyAckQueueSize      = config.GetInt("EARLY_ACK_QUEUE_SIZE", 10000)
+	earlyAckWorkers        = config.GetInt("EARLY_ACK_WORKERS", 4)
+	earlyAckRetryDelay     = config.GetDuration("EARLY_ACK_RETRY_DELAY", 100*time.Millisecond)
+	earlyAckRecoveryPeriod = config.GetDuration("EARLY_ACK_RECOVERY_INTERVAL", 30*time.Second)
+
+	// Tentativas por rodada, com backoff dobrando a partir de EARLY_ACK_RETRY_DELAY
+	// até EARLY_ACK_MAX_RETRY_DELAY; esgotadas, o pagamento continua pendente e
+	// volta na próxima varredura de recuperação, sem prender o worker
+	earlyAckMaxAttempts   = config.GetInt("EARLY_ACK_MAX_ATTEMPTS", 5)
+	earlyAckMaxRetryDelay = config.GetDuration("EARLY_ACK_MAX_RETRY_DELAY", 2*time.Second)
+)
+
+const (
+	paymentPending   = "pending"
+	paymentCompleted = "completed"
+	paymentFailed    = "failed" // recusado pelo orchestrator com 4xx; não é reenviado
+)
+
+// Fila de pagamentos aceitos aguardando envio ao orchestrator
+type earlyAckQueue struct {
+	db      *database.Database
+	gateway *Gateway
+	jobs    chan PaymentRequest
+
+	// IDs na fila ou em processamento, para a recuperação não enfileirar de novo
+	queued   map[string]struct{}
+	queuedMu sync.Mutex
+}
+
+// startEarlyAck reenfileira os pendentes de execuções anteriores (at-least-once)
+// e inicia os workers; a idempotência do orchestrator garante efeito único
+// mesmo se um pagamento for enviado mais de uma vez. Requer o banco do gateway.
+func startEarlyAck(g *Gateway) *earlyAckQueue {
+	if g.db == nil {
+		log.Fatal("EARLY_ACK requires GATEWAY_DB_PATH")
+	}
+	q := &earlyAckQueue{
+		db:      g.db,
+		gateway: g,
+		jobs:    make(chan PaymentRequest, earlyAckQueueSize),
+		queued:  make(map[string]struct{}),
+	}
+	for i := 0; i < earlyAckWorkers; i++ {
+		go q.worker()
+	}
+	go q.recover()
+	return q
+}
+
+// accept grava o pagamento como pendente; só depois disso o 202 é seguro
+func (q *earlyAckQueue) accept(paymentReq PaymentRequest) error {
+	now := time.Now()
+	err := q.db.CreatePayment(&database.Payment{
+		ID:          paymentReq.CorrelationID,
+		CustomerID:  paymentReq.CustomerID,
+		Amount:      paymentReq.Amount,
+		Description: paymentReq.Description,
+		Status:      paymentPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+	if err != nil {
+		return err
+	}
+	// Fila cheia: o pagamento já está gravado e a varredura de recuperação o pega
+	q.enqueue(paymentReq)
+	return nil
+}
+
+// enqueue coloca o pagamento na fila sem bloquear; false se já estava na fila
+// (ou em processamento) ou se a fila está cheia
+func (q *earlyAckQueue) enqueue(paymentReq PaymentRequest) bool {
+	q.queuedMu.Lock()
+	defer q.queuedMu.Unlock()
+	if _, ok := q.queued[paymentReq.CorrelationID]; ok {
+		return false
+	}
+	select {
+	case q.jobs <- paymentReq:
+		q.queued[paymentReq.CorrelationID] = struct{}{}
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *earlyAckQueue) done(id string) {
+	q.queuedMu.Lock()
+	defer q.queuedMu.Unlock()
+	delete(q.queued, id)
+}
+
+func (q *earlyAckQueue) worker() {
+	for paymentReq := range q.jobs {
+		q.process(paymentReq)
+		q.done(paymentReq.CorrelationID)
+	}
+}
+
+// process envia o pagamento ao orchestrator com backoff. Sucesso conclui com o
+// processor usado, 4xx marca como falho (reenviar não muda a resposta) e as
+// demais falhas deixam pendente para a próxima recuperação.
+func (q *earlyAckQueue) process(paymentReq PaymentRequest) {
+	delay := earlyAckRetryDelay
+	for attempt := 1; ; attempt++ {
+		resp := q.gateway.callPaymentOrchestrator(paymentReq)
+		switch {
+		case resp.Status != "error":
+			q.finish(paymentReq.CorrelationID, paymentCompleted, resp.Processor)
+			return
+		case isClientError(resp.statusCode):
+			log.Printf("Early-ack: %s rejected: %s", paymentReq.CorrelationID, resp.Message)
+			q.finish(paymentReq.CorrelationID, paymentFailed, "")
+			return
+		case attempt >= earlyAckMaxAttempts:
+			log.Printf("Early-ack: %s still pending after %d attempts: %s", paymentReq.CorrelationID, attempt, resp.Message)
+			return
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > earlyAckMaxRetryDelay {
+			delay = earlyAckMaxRetryDelay
+		}
+	}
+}
+
+func (q *earlyAckQueue) finish(id, status, processor string) {
+	err := q.db.UpdatePayment(&database.Payment{
+		ID:            id,
+		Status:        status,
+		ProcessorUsed: processor,
+		UpdatedAt:     time.Now(),
+	})
+	if err != nil {
+		log.Printf("Early-ack: failed to mark %s as %s: %v", id, status, err)
+	}
+}
+
+// recover reenfileira os pendentes na inicialização e depois periodicamente
+// os que estão parados há mais de um intervalo (ex.: fila cheia no aceite)
+func (q *earlyAckQueue) recover() {
+	q.enqueuePending(time.Time{})
+	if earlyAckRecoveryPeriod <= 0 {
+		return
+	}
+	ticker := time.NewTicker(earlyAckRecoveryPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		q.enqueuePending(time.Now().Add(-earlyAckRecoveryPeriod))
+	}
+}
+
+func (q *earlyAckQueue) enqueuePending(olderThan time.Time) {
+	pending, err := q.db.GetPaymentsByStatus(paymentPending)
+	if err != nil {
+		log.Printf("Early-ack: recovery scan failed: %v", err)
+		return
+	}
+	requeued := 0
+	for _, p := range pending {
+		if !olderThan.IsZero() && p.UpdatedAt.After(olderThan) {
+			continue
+		}
+		// Sem bloquear: fila cheia fica para a próxima varredura
+		if q.enqueue(PaymentRequest{
+			CorrelationID: p.ID,
+			Amount:        p.Amount,
+			CustomerID:    p.CustomerID,
+			Description:   p.Description,
+		}) {
+			requeued++
+		}
+	}
+	if requeued > 0 {
+		log.Printf("Early-ack: %d pending payments requeued", requeued)
+	}
+}
+
+// GET /payments/{id}: status de um pagamento aceito em modo early-ack
+func (q *earlyAckQueue) handlePaymentStatus(w http.ResponseWriter, r *http.Request) {
+	payment, err := q.db.GetPaymentByID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(HTTPPaymentResponse{
+		ID:      payment.ID,
+		Status:  payment.Status,
+		Message: "Early-ack payment",
+	})
+}
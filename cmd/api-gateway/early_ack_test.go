@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
+)
+
+func withFastEarlyAckRetries(t *testing.T) {
+	t.Helper()
+	prevDelay, prevMax := earlyAckRetryDelay, earlyAckMaxRetryDelay
+	earlyAckRetryDelay, earlyAckMaxRetryDelay = 5*time.Millisecond, 20*time.Millisecond
+	t.Cleanup(func() { earlyAckRetryDelay, earlyAckMaxRetryDelay = prevDelay, prevMax })
+}
+
+// waitForStatus espera o pagamento sair de pendente, até o timeout
+func waitForStatus(t *testing.T, db *database.Database, id string) *database.Payment {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if p, err := db.GetPaymentByID(id); err == nil && p.Status != paymentPending {
+			return p
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("payment %s still pending", id)
+	return nil
+}
+
+func TestEarlyAckPaymentEventuallyCompletes(t *testing.T) {
+	withFastEarlyAckRetries(t)
+	var calls atomic.Int64
+	withOrchestrator(t, func(w http.ResponseWriter, r *http.Request) {
+		// Duas falhas transitórias antes do sucesso
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"id":"ea-1","status":"processed","message":"ok","processor":"default"}`))
+	})
+	g := newTestGateway(t, true)
+	g.earlyAck = startEarlyAck(g)
+
+	rec := postPayment(g, `{"correlationId":"ea-1","amount":42}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", rec.Code)
+	}
+
+	p := waitForStatus(t, g.db, "ea-1")
+	if p.Status != paymentCompleted || p.ProcessorUsed != "default" {
+		t.Fatalf("payment = %+v, want completed by default", p)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("orchestrator calls = %d, want 3", n)
+	}
+}
+
+func TestEarlyAckClientErrorMarksFailed(t *testing.T) {
+	withFastEarlyAckRetries(t)
+	var calls atomic.Int64
+	withOrchestrator(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	g := newTestGateway(t, true)
+	g.earlyAck = startEarlyAck(g)
+
+	postPayment(g, `{"correlationId":"ea-2","amount":42}`)
+
+	if p := waitForStatus(t, g.db, "ea-2"); p.Status != paymentFailed {
+		t.Fatalf("status = %s, want %s", p.Status, paymentFailed)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("orchestrator calls = %d, want 1 (4xx is not retried)", n)
+	}
+}
+
+func TestEarlyAckRecoveryDoesNotBlockOrDuplicate(t *testing.T) {
+	g := newTestGateway(t, true)
+	// Sem workers: a fila só enche
+	q := &earlyAckQueue{db: g.db, gateway: g, jobs: make(chan PaymentRequest, 2), queued: make(map[string]struct{})}
+
+	for _, id := range []string{"r-1", "r-2", "r-3"} {
+		if err := q.accept(PaymentRequest{CorrelationID: id, Amount: 1}); err != nil {
+			t.Fatalf("accept %s: %v", id, err)
+		}
+	}
+	if len(q.jobs) != 2 {
+		t.Fatalf("queued jobs = %d, want 2 (queue size)", len(q.jobs))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		q.enqueuePending(time.Time{})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("enqueuePending blocked on a full queue")
+	}
+	if len(q.jobs) != 2 {
+		t.Fatalf("queued jobs after recovery = %d, want 2 (no duplicates)", len(q.jobs))
+	}
+
+	// Um worker pega r-1 (ainda em processamento): a recuperação usa o lugar
+	// livre para r-3 e não reenfileira r-1
+	if first := <-q.jobs; first.CorrelationID != "r-1" {
+		t.Fatalf("first job = %s, want r-1", first.CorrelationID)
+	}
+	q.enqueuePending(time.Time{})
+	seen := map[string]int{}
+	for len(q.jobs) > 0 {
+		seen[(<-q.jobs).CorrelationID]++
+	}
+	if seen["r-2"] != 1 || seen["r-3"] != 1 || seen["r-1"] != 0 {
+		t.Fatalf("queued = %v, want r-2 and r-3 once each", seen)
+	}
+}
@@ -2,20 +2,42 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/cache"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/cachereg"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/dedup"
 	rinha "github.com/lucas-de-lima/rinha-de-backend-2025/internal/gen/proto/proto"
 	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/keys"
 )
 
 var (
+	// Transições de estado do circuit breaker
+	breakerTransitionCount int64
+
+	// Tamanho máximo do corpo de POST /payments
+	maxPaymentBodyBytes = int64(config.GetInt("MAX_PAYMENT_BODY_BYTES", 16*1024))
+
+	// CORRELATION_ID_MODE: strict (padrão) rejeita pagamento sem correlationId com 400;
+	// lenient gera um UUID no servidor e o devolve na resposta e no header Location
+	correlationIDMode = config.GetString("CORRELATION_ID_MODE", "strict")
+
 	// BRUTO Connection Pool - GIGANTE
 	brutoConnectionPool = &BRUTOConnectionPool{
 		connections: make([]*grpc.ClientConn, 0),
@@ -24,38 +46,43 @@ var (
 	}
 
 	// BRUTO Cache - ULTRA RÁPIDO
-	brutoCache = &BRUTOCache{
-		data: make(map[string]interface{}),
-		mu:   sync.RWMutex{},
-	}
-
-	// Circuit breaker BRUTO - MAIS AGRESSIVO
-	circuitBreaker = &CircuitBreaker{
-		failures:    0,
-		lastFailure: time.Time{},
-		state:       CLOSED,
-		mux:         sync.RWMutex{},
-	}
-
-	// Buffer pools for zero-copy operations
-	bufferPool = sync.Pool{
-		New: func() interface{} {
-			return make([]byte, 0, 4096)
-		},
-	}
+	brutoCache = cache.New[cachedSummary](config.GetInt("CACHE_MAX_ENTRIES", 10000))
+
+	// Circuit breaker BRUTO - MAIS AGRESSIVO (padrão: 3 falhas / 10s)
+	// CB_WINDOW_SIZE > 0 troca as falhas consecutivas por janela deslizante (CB_FAILURE_PERCENT)
+	circuitBreaker = NewCircuitBreaker(
+		config.GetInt("CB_THRESHOLD", 3),
+		config.GetDuration("CB_OPEN_TIMEOUT", 10*time.Second),
+		breakerStateLogger("gateway"),
+	).withWindow(config.GetInt("CB_WINDOW_SIZE", 0), float64(config.GetInt("CB_FAILURE_PERCENT", 50))/100).
+		withMaxProbes(config.GetInt("CB_HALF_OPEN_PROBES", 1)).
+		withMaxOpenTimeout(config.GetDuration("CB_MAX_OPEN_TIMEOUT", 2*time.Minute))
 )
 
-// Deduplicação de pagamentos (escopo global) - ULTRA RÁPIDA
-var processedPayments = struct {
-	m map[string]struct{}
-	sync.RWMutex
-}{m: make(map[string]struct{})}
-
 type CircuitBreaker struct {
+	threshold   int           // falhas para abrir
+	openTimeout time.Duration // tempo aberto antes de HALF_OPEN
+	maxTimeout  time.Duration // teto do openTimeout com backoff (0 = sem backoff)
+	reopenCount int           // reaberturas seguidas a partir de HALF_OPEN
 	failures    int
 	lastFailure time.Time
 	state       CircuitState
 	mux         sync.RWMutex
+
+	// Chamado fora do lock a cada mudança de estado
+	onStateChange func(from, to CircuitState)
+
+	// HALF_OPEN: chamadas de teste em andamento, limitadas a maxProbes
+	maxProbes      int
+	probesInFlight atomic.Int32
+	probeStartedAt time.Time
+
+	// Janela deslizante (withWindow): últimos N resultados, true = falha
+	window         []bool
+	windowPos      int
+	windowCount    int
+	windowFailures int
+	failureRatio   float64
 }
 
 type CircuitState int
@@ -66,6 +93,25 @@ const (
 	HALF_OPEN
 )
 
+func (s CircuitState) String() string {
+	switch s {
+	case CLOSED:
+		return "CLOSED"
+	case OPEN:
+		return "OPEN"
+	case HALF_OPEN:
+		return "HALF_OPEN"
+	}
+	return "UNKNOWN"
+}
+
+// Estado do breaker para leitura externa (GET /circuit-breaker)
+type CircuitBreakerSnapshot struct {
+	State       string    `json:"state"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"lastFailure"`
+}
+
 // BRUTO Connection Pool - GIGANTE
 type BRUTOConnectionPool struct {
 	connections []*grpc.ClientConn
@@ -73,6 +119,18 @@ type BRUTOConnectionPool struct {
 	mu          sync.Mutex
 }
 
+// Close fecha as conexões gRPC do pool e as conexões keep-alive ociosas do
+// client HTTP do orchestrator
+func (p *BRUTOConnectionPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conn := range p.connections {
+		conn.Close()
+	}
+	p.connections = nil
+	orchestratorHTTPClient.CloseIdleConnections()
+}
+
 func (p *BRUTOConnectionPool) GetConnection() *grpc.ClientConn {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -84,29 +142,16 @@ func (p *BRUTOConnectionPool) GetConnection() *grpc.ClientConn {
 	return conn
 }
 
-// BRUTO Cache - ULTRA RÁPIDO
-type BRUTOCache struct {
-	data map[string]interface{}
-	mu   sync.RWMutex
-}
-
-func (c *BRUTOCache) Get(key string) interface{} {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.data[key]
-}
-
-func (c *BRUTOCache) Set(key string, value interface{}) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.data[key] = value
-}
-
 // BRUTO Payment Response
 type HTTPPaymentResponse struct {
-	ID      string `json:"id"`
-	Status  string `json:"status"`
-	Message string `json:"message"`
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	Processor string `json:"processor,omitempty"` // processor que cobrou; vazio nas respostas locais
+
+	RequestedAt string `json:"requestedAt,omitempty"` // requestedAt enviado ao processor (só no transporte HTTP)
+
+	statusCode int // status HTTP da falha, quando conhecido (4xx do orchestrator, 503 do breaker)
 }
 
 // BRUTO Summary Response
@@ -120,49 +165,257 @@ type ProcessorSummary struct {
 	TotalAmount   float64 `json:"totalAmount"`
 }
 
-func (cb *CircuitBreaker) canExecute() bool {
+// NewCircuitBreaker cria um breaker fechado que abre após threshold falhas
+// e tenta de novo (HALF_OPEN) depois de openTimeout; onStateChange (opcional)
+// é chamado uma vez por transição, sem o lock do breaker
+func NewCircuitBreaker(threshold int, openTimeout time.Duration, onStateChange func(from, to CircuitState)) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold:     threshold,
+		openTimeout:   openTimeout,
+		state:         CLOSED,
+		onStateChange: onStateChange,
+		maxProbes:     1,
+	}
+}
+
+// withMaxOpenTimeout faz o tempo aberto dobrar a cada reabertura vinda de
+// HALF_OPEN (30s, 60s, 120s...) até max; volta à base quando o breaker fecha
+func (cb *CircuitBreaker) withMaxOpenTimeout(max time.Duration) *CircuitBreaker {
+	cb.maxTimeout = max
+	return cb
+}
+
+// effectiveOpenTimeoutLocked aplica o backoff exponencial ao openTimeout
+func (cb *CircuitBreaker) effectiveOpenTimeoutLocked() time.Duration {
+	timeout := cb.openTimeout
+	for i := 0; i < cb.reopenCount && timeout < cb.maxTimeout; i++ {
+		timeout *= 2
+	}
+	if cb.maxTimeout > 0 && timeout > cb.maxTimeout {
+		timeout = cb.maxTimeout
+	}
+	return timeout
+}
+
+// withMaxProbes define quantas chamadas de teste o HALF_OPEN admite ao mesmo tempo
+func (cb *CircuitBreaker) withMaxProbes(n int) *CircuitBreaker {
+	if n > 0 {
+		cb.maxProbes = n
+	}
+	return cb
+}
+
+// Transição pendente de notificação, registrada sob o lock
+type stateTransition struct {
+	from, to CircuitState
+	changed  bool
+}
+
+// setStateLocked muda o estado e registra a transição; chamado com mux travado
+func (cb *CircuitBreaker) setStateLocked(t *stateTransition, to CircuitState) {
+	if cb.state == to {
+		return
+	}
+	switch {
+	case cb.state == HALF_OPEN && to == OPEN:
+		cb.reopenCount++
+	case to == CLOSED:
+		cb.reopenCount = 0
+	}
+	t.from, t.to, t.changed = cb.state, to, true
+	cb.state = to
+}
+
+// notify dispara o callback; deve rodar depois do Unlock (defer antes do Lock)
+func (cb *CircuitBreaker) notify(t *stateTransition) {
+	if t.changed && cb.onStateChange != nil {
+		cb.onStateChange(t.from, t.to)
+	}
+}
+
+// Snapshot copia o estado atual sob o lock de leitura
+func (cb *CircuitBreaker) Snapshot() CircuitBreakerSnapshot {
+	cb.mux.RLock()
+	defer cb.mux.RUnlock()
+	return CircuitBreakerSnapshot{
+		State:       cb.state.String(),
+		Failures:    cb.failures,
+		LastFailure: cb.lastFailure,
+	}
+}
+
+// currentState retorna o estado atual (para o gauge do Prometheus)
+func (cb *CircuitBreaker) currentState() CircuitState {
 	cb.mux.RLock()
 	defer cb.mux.RUnlock()
+	return cb.state
+}
+
+// canExecute indica se a chamada pode seguir; probe=true marca uma chamada de
+// teste do HALF_OPEN, cujo resultado deve ir para probeFinished (ou recordResult)
+func (cb *CircuitBreaker) canExecute() (allowed, probe bool) {
+	// Caminho comum (CLOSED) só com o lock de leitura
+	cb.mux.RLock()
+	state := cb.state
+	cb.mux.RUnlock()
+	if state == CLOSED {
+		return true, false
+	}
 
+	// Relê o estado sob o lock de escrita, já que outra goroutine pode ter
+	// mudado entre os dois locks, e faz a transição para HALF_OPEN de forma atômica
+	var t stateTransition
+	defer cb.notify(&t)
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
 	switch cb.state {
 	case CLOSED:
-		return true
+		return true, false
 	case OPEN:
-		if time.Since(cb.lastFailure) > 10*time.Second { // BRUTO: 10s em vez de 30s
-			cb.mux.Lock()
-			cb.state = HALF_OPEN
-			cb.mux.Unlock()
-			return true
+		if time.Since(cb.lastFailure) <= cb.effectiveOpenTimeoutLocked() {
+			return false, false
 		}
-		return false
-	case HALF_OPEN:
-		return true
+		cb.setStateLocked(&t, HALF_OPEN)
+		cb.probesInFlight.Store(0)
+	}
+
+	// HALF_OPEN: no máximo maxProbes chamadas de teste por vez; uma sonda
+	// sem resultado há mais de openTimeout é dada como perdida e libera a vaga
+	if int(cb.probesInFlight.Load()) >= cb.maxProbes {
+		if time.Since(cb.probeStartedAt) <= cb.openTimeout {
+			return false, false
+		}
+		cb.probesInFlight.Store(0)
 	}
-	return false
+	cb.probesInFlight.Add(1)
+	cb.probeStartedAt = time.Now()
+	return true, true
 }
 
-func (cb *CircuitBreaker) recordSuccess() {
+// Reset força o breaker para CLOSED, zerando falhas, janela e backoff (uso administrativo)
+func (cb *CircuitBreaker) Reset() {
+	var t stateTransition
+	defer cb.notify(&t)
 	cb.mux.Lock()
 	defer cb.mux.Unlock()
-	cb.failures = 0
-	cb.state = CLOSED
+	cb.resetWindow()
+	cb.lastFailure = time.Time{}
+	cb.probesInFlight.Store(0)
+	cb.setStateLocked(&t, CLOSED)
+	cb.reopenCount = 0
+}
+
+// probeFinished libera a vaga da chamada de teste e registra seu resultado
+func (cb *CircuitBreaker) probeFinished(success bool) {
+	cb.releaseProbe()
+	cb.recordOutcome(success)
 }
 
-func (cb *CircuitBreaker) recordFailure() {
+// releaseProbe libera a vaga de uma chamada de teste que não chegou ao downstream
+func (cb *CircuitBreaker) releaseProbe() {
+	if cb.probesInFlight.Add(-1) < 0 {
+		cb.probesInFlight.Store(0)
+	}
+}
+
+// recordResult registra o resultado de uma chamada admitida por canExecute
+func (cb *CircuitBreaker) recordResult(probe, success bool) {
+	if probe {
+		cb.probeFinished(success)
+		return
+	}
+	cb.recordOutcome(success)
+}
+
+// breakerStateLogger registra cada transição do breaker no log e em /metrics
+func breakerStateLogger(name string) func(from, to CircuitState) {
+	return func(from, to CircuitState) {
+		atomic.AddInt64(&breakerTransitionCount, 1)
+		log.Printf("Circuit breaker %s: %s -> %s", name, from, to)
+	}
+}
+
+// withWindow troca o contador de falhas consecutivas por uma janela com os
+// últimos size resultados: o breaker abre quando a janela está cheia e a
+// fração de falhas passa de ratio (ex.: 20 chamadas, 0.5)
+func (cb *CircuitBreaker) withWindow(size int, ratio float64) *CircuitBreaker {
+	if size > 0 {
+		cb.window = make([]bool, size)
+		cb.failureRatio = ratio
+	}
+	return cb
+}
+
+func (cb *CircuitBreaker) recordOutcome(success bool) {
+	var t stateTransition
+	defer cb.notify(&t)
 	cb.mux.Lock()
 	defer cb.mux.Unlock()
-	cb.failures++
-	cb.lastFailure = time.Now()
-	if cb.failures >= 3 { // BRUTO: 3 falhas em vez de 5
-		cb.state = OPEN
+	if !success {
+		cb.lastFailure = time.Now()
+	}
+
+	if cb.window == nil {
+		if success {
+			cb.failures = 0
+			cb.setStateLocked(&t, CLOSED)
+			return
+		}
+		cb.failures++
+		if cb.failures >= cb.threshold {
+			cb.setStateLocked(&t, OPEN)
+		}
+		return
+	}
+
+	// Fora do CLOSED a chamada de teste decide sozinha, e a janela recomeça
+	if cb.state != CLOSED {
+		if success {
+			cb.setStateLocked(&t, CLOSED)
+			cb.resetWindow()
+		} else {
+			cb.setStateLocked(&t, OPEN)
+		}
+		return
+	}
+
+	if cb.windowCount == len(cb.window) {
+		if cb.window[cb.windowPos] {
+			cb.windowFailures--
+		}
+	} else {
+		cb.windowCount++
+	}
+	cb.window[cb.windowPos] = !success
+	if !success {
+		cb.windowFailures++
+	}
+	cb.windowPos = (cb.windowPos + 1) % len(cb.window)
+	cb.failures = cb.windowFailures
+
+	if cb.windowCount == len(cb.window) && float64(cb.windowFailures)/float64(cb.windowCount) > cb.failureRatio {
+		cb.setStateLocked(&t, OPEN)
 	}
 }
 
+func (cb *CircuitBreaker) resetWindow() {
+	for i := range cb.window {
+		cb.window[i] = false
+	}
+	cb.windowPos, cb.windowCount, cb.windowFailures, cb.failures = 0, 0, 0, 0
+}
+
 // BRUTO: Call Payment Orchestrator - ULTRA AGRESSIVO
 func (g *Gateway) callPaymentOrchestratorBRUTO(paymentReq PaymentRequest) HTTPPaymentResponse {
+	resp, _ := g.callPaymentOrchestratorGRPC(paymentReq)
+	return resp
+}
+
+// callPaymentOrchestratorGRPC devolve também o erro do gRPC para o modo com fallback
+func (g *Gateway) callPaymentOrchestratorGRPC(paymentReq PaymentRequest) (HTTPPaymentResponse, error) {
 	conn := brutoConnectionPool.GetConnection()
 	if conn == nil {
-		return HTTPPaymentResponse{Status: "error", Message: "No connection available"}
+		return HTTPPaymentResponse{Status: "error", Message: "No connection available"}, status.Error(codes.Unavailable, "no connection available")
 	}
 
 	client := rinha.NewPaymentOrchestratorServiceClient(conn)
@@ -172,22 +425,25 @@ func (g *Gateway) callPaymentOrchestratorBRUTO(paymentReq PaymentRequest) HTTPPa
 	req := &rinha.OrchestratePaymentRequest{
 		Amount:      paymentReq.Amount,
 		PaymentId:   paymentReq.CorrelationID,
-		CustomerId:  "default",
-		Description: "Payment",
+		CustomerId:  paymentReq.CustomerID,
+		Description: paymentReq.Description,
 	}
 
 	resp, err := client.OrchestratePayment(ctx, req)
 	if err != nil {
-		circuitBreaker.recordFailure()
-		return HTTPPaymentResponse{Status: "error", Message: "Orchestrator failed"}
+		if status.Code(err) == codes.DeadlineExceeded {
+			atomic.AddInt64(&timeoutCount, 1)
+		}
+		return HTTPPaymentResponse{Status: "error", Message: "Orchestrator failed"}, err
 	}
 
-	circuitBreaker.recordSuccess()
+	atomic.AddInt64(&grpcServedCount, 1)
 	return HTTPPaymentResponse{
-		ID:      resp.PaymentId,
-		Status:  "processed",
-		Message: "Orchestrator processing",
-	}
+		ID:        resp.PaymentId,
+		Status:    "processed",
+		Message:   "Orchestrator processing",
+		Processor: resp.ProcessorUsed,
+	}, nil
 }
 
 // BRUTO: Call Summary Service - ULTRA AGRESSIVO
@@ -229,96 +485,220 @@ func (g *Gateway) callSummaryServiceBRUTO() HTTPSummaryResponse {
 type PaymentRequest struct {
 	CorrelationID string  `json:"correlationId"`
 	Amount        float64 `json:"amount"`
+	CustomerID    string  `json:"customerId"`
+	Description   string  `json:"description"`
+}
+
+// Defaults dos campos opcionais do pagamento
+const (
+	defaultCustomerID  = "default"
+	defaultDescription = "Payment"
+)
+
+// Corpo de POST /payments: ponteiros distinguem campo ausente/null de valor zero
+type paymentRequestBody struct {
+	CorrelationID *string  `json:"correlationId"`
+	Amount        *float64 `json:"amount"`
+	CustomerID    *string  `json:"customerId"`
+	Description   *string  `json:"description"`
+}
+
+// toPaymentRequest valida os campos obrigatórios (correlationId e amount não
+// podem ser ausentes nem null) e aplica os defaults dos opcionais
+// (customerId="default", description="Payment" quando ausentes, null ou vazios)
+func (b paymentRequestBody) toPaymentRequest() (PaymentRequest, error) {
+	if b.CorrelationID == nil || *b.CorrelationID == "" {
+		return PaymentRequest{}, errors.New("correlationId is required")
+	}
+	if b.Amount == nil {
+		return PaymentRequest{}, errors.New("amount is required")
+	}
+	if *b.Amount <= 0 {
+		return PaymentRequest{}, errors.New("amount must be positive")
+	}
+	req := PaymentRequest{
+		CorrelationID: *b.CorrelationID,
+		Amount:        *b.Amount,
+		CustomerID:    defaultCustomerID,
+		Description:   defaultDescription,
+	}
+	if b.CustomerID != nil && *b.CustomerID != "" {
+		req.CustomerID = *b.CustomerID
+	}
+	if b.Description != nil && *b.Description != "" {
+		req.Description = *b.Description
+	}
+	return req, nil
+}
+
+// newCorrelationID gera um UUID v4 para pagamentos sem correlationId (modo lenient)
+func newCorrelationID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
 }
 
 type Gateway struct {
 	paymentOrchestratorURL string
 	summaryServiceURL      string
 	keyStore               *keys.KeyStore
+	deduper                dedup.Deduper
+	earlyAck               *earlyAckQueue     // nil fora do modo early-ack
+	db                     *database.Database // nil com GATEWAY_DB_PATH vazio
 }
 
 func (g *Gateway) handlePayments(w http.ResponseWriter, r *http.Request) {
+	// Fast reject pelo Content-Length declarado, antes de ler o corpo
+	if r.ContentLength > maxPaymentBodyBytes {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	// Limite autoritativo, cobre também corpos chunked sem Content-Length
+	r.Body = http.MaxBytesReader(w, r.Body, maxPaymentBodyBytes)
+
 	// Parse request
-	var paymentReq PaymentRequest
-	if err := json.NewDecoder(r.Body).Decode(&paymentReq); err != nil {
+	var body paymentRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		// Campo com tipo errado (ex.: correlationId numérico)
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			http.Error(w, typeErr.Field+" must be a "+typeErr.Type.String(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	// Validate UUID
-	if paymentReq.CorrelationID == "" {
-		http.Error(w, "correlationId is required", http.StatusBadRequest)
-		return
+	// Sem correlationId, o modo lenient gera um ID em vez de rejeitar
+	generatedID := false
+	if (body.CorrelationID == nil || *body.CorrelationID == "") && correlationIDMode == "lenient" {
+		id, err := newCorrelationID()
+		if err != nil {
+			http.Error(w, "Failed to generate correlationId", http.StatusInternalServerError)
+			return
+		}
+		body.CorrelationID = &id
+		generatedID = true
 	}
 
-	// Validate amount
-	if paymentReq.Amount <= 0 {
-		http.Error(w, "amount must be positive", http.StatusBadRequest)
+	// Validate correlationId/amount and apply optional defaults
+	paymentReq, err := body.toPaymentRequest()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Check deduplication - ULTRA RÁPIDO
-	processedPayments.RLock()
-	_, exists := processedPayments.m[paymentReq.CorrelationID]
-	processedPayments.RUnlock()
+	exists, err := g.deduper.Seen(paymentReq.CorrelationID)
+	if err != nil {
+		log.Printf("Dedup check failed for %s: %v", paymentReq.CorrelationID, err)
+		if !dedup.FailOpen() {
+			http.Error(w, "Dedup unavailable", http.StatusServiceUnavailable)
+			return
+		}
+	}
 
 	if exists {
 		http.Error(w, "Payment already processed", http.StatusConflict)
 		return
 	}
 
-	// BRUTO: 4 estratégias em paralelo - PEGA O PRIMEIRO!
-	resultChan := make(chan HTTPPaymentResponse, 4)
-
-	// Estratégia 1: Payment Orchestrator
-	go func() {
-		if resp := g.callPaymentOrchestratorBRUTO(paymentReq); resp.Status != "error" {
-			resultChan <- resp
+	// Early-ack: 202 (aceito, pendente) após gravar, processamento assíncrono;
+	// 200 continua significando processado
+	if g.earlyAck != nil {
+		if err := g.earlyAck.accept(paymentReq); err != nil {
+			log.Printf("Early-ack persist failed for %s: %v", paymentReq.CorrelationID, err)
+			http.Error(w, "Failed to accept payment", http.StatusServiceUnavailable)
+			return
 		}
-	}()
-
-	// Estratégia 2: Direct Processing
-	go func() {
-		resultChan <- HTTPPaymentResponse{
-			ID:      paymentReq.CorrelationID,
-			Status:  "processed",
-			Message: "Direct processing",
+		if err := g.deduper.Mark(paymentReq.CorrelationID); err != nil {
+			log.Printf("Dedup mark failed for %s: %v", paymentReq.CorrelationID, err)
 		}
-	}()
-
-	// Estratégia 3: Local Processing
-	go func() {
-		resultChan <- HTTPPaymentResponse{
+		w.Header().Set("Location", "/payments/"+paymentReq.CorrelationID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(HTTPPaymentResponse{
 			ID:      paymentReq.CorrelationID,
-			Status:  "processed",
-			Message: "Local processing",
-		}
-	}()
+			Status:  paymentPending,
+			Message: "Accepted for processing",
+		})
+		return
+	}
 
-	// Estratégia 4: Cache Processing
-	go func() {
-		resultChan <- HTTPPaymentResponse{
-			ID:      paymentReq.CorrelationID,
-			Status:  "processed",
-			Message: "Cache processing",
+	// Só o orchestrator cobra: respostas locais confirmariam pagamentos que
+	// nenhum processor recebeu
+	result := g.callPaymentOrchestrator(paymentReq)
+	if result.Status == "error" {
+		log.Printf("Payment %s failed: %s", paymentReq.CorrelationID, result.Message)
+		// Breaker aberto (503) e 4xx do orchestrator passam adiante; o resto é 502
+		code := http.StatusBadGateway
+		if result.statusCode == http.StatusServiceUnavailable || isClientError(result.statusCode) {
+			code = result.statusCode
 		}
-	}()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(HTTPPaymentResponse{
+			ID:      paymentReq.CorrelationID,
+			Status:  "error",
+			Message: result.Message,
+		})
+		return
+	}
 
-	// PEGA O PRIMEIRO QUE RESPONDER!
-	result := <-resultChan
+	// Cobrado por um processor real: grava o pagamento
+	if result.Processor != "" {
+		g.persistPayment(paymentReq, result)
+	}
 
 	// Mark as processed
-	processedPayments.Lock()
-	processedPayments.m[paymentReq.CorrelationID] = struct{}{}
-	processedPayments.Unlock()
+	if err := g.deduper.Mark(paymentReq.CorrelationID); err != nil {
+		log.Printf("Dedup mark failed for %s: %v", paymentReq.CorrelationID, err)
+	}
 
 	// Return response
+	if generatedID {
+		result.ID = paymentReq.CorrelationID
+		w.Header().Set("Location", "/payments/"+paymentReq.CorrelationID)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(result)
 }
 
 func (g *Gateway) handlePaymentsSummary(w http.ResponseWriter, r *http.Request) {
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	cacheKey, ranged := summaryCacheKey(from, to)
+
+	// Com from/to: só os pagamentos do intervalo, direto do summary-service
+	if ranged {
+		if err := parseSummaryRange(from, to); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		summary, ok := getCachedSummary(cacheKey)
+		if !ok {
+			var err error
+			if summary, err = callSummaryServiceRange(from, to); err != nil {
+				log.Printf("Ranged summary failed: %v", err)
+				http.Error(w, "Summary unavailable", http.StatusBadGateway)
+				return
+			}
+			setCachedSummary(cacheKey, true, summary)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+		return
+	}
+
 	// BRUTO: 3 estratégias em paralelo
 	resultChan := make(chan HTTPSummaryResponse, 3)
 
@@ -331,6 +711,10 @@ func (g *Gateway) handlePaymentsSummary(w http.ResponseWriter, r *http.Request)
 
 	// Estratégia 2: Cache
 	go func() {
+		if summary, ok := getCachedSummary(cacheKey); ok {
+			resultChan <- summary
+			return
+		}
 		resultChan <- HTTPSummaryResponse{
 			Default:  ProcessorSummary{TotalRequests: 0, TotalAmount: 0},
 			Fallback: ProcessorSummary{TotalRequests: 0, TotalAmount: 0},
@@ -347,6 +731,7 @@ func (g *Gateway) handlePaymentsSummary(w http.ResponseWriter, r *http.Request)
 
 	// PEGA O PRIMEIRO QUE RESPONDER!
 	result := <-resultChan
+	setCachedSummary(cacheKey, ranged, result)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -360,6 +745,25 @@ func main() {
 		// BRUTO: Não falha, continua sem keys
 	}
 
+	// Deduplicação: backend selecionado por DEDUP_BACKEND (memory, file, redis)
+	deduper, err := dedup.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize dedup: %v", err)
+	}
+	defer deduper.Close()
+
+	// Registro de caches para /admin/memory e limite suave (CACHE_SOFT_LIMIT)
+	dedup.RegisterCache(deduper)
+	cachereg.Register("bruto_cache", brutoCache.Len, func() {
+		brutoCache.Clear()
+		summaryRanges.forget()
+	})
+	cachereg.StartLimiter(5 * time.Second)
+
+	// Remoção periódica das entradas expiradas do brutoCache
+	stopJanitor := brutoCache.StartJanitor(config.GetDuration("CACHE_JANITOR_INTERVAL", 10*time.Second))
+	defer stopJanitor()
+
 	// Initialize connection pool - GIGANTE
 	paymentOrchestratorConn, err := grpc.Dial("payment-orchestrator:8444",
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
@@ -368,8 +772,6 @@ func main() {
 	)
 	if err != nil {
 		// BRUTO: Não falha, continua sem conexão
-	} else {
-		defer paymentOrchestratorConn.Close()
 	}
 
 	summaryServiceConn, err := grpc.Dial("summary-service:8445",
@@ -379,8 +781,6 @@ func main() {
 	)
 	if err != nil {
 		// BRUTO: Não falha, continua sem conexão
-	} else {
-		defer summaryServiceConn.Close()
 	}
 
 	// Add connections to pool
@@ -395,6 +795,11 @@ func main() {
 		paymentOrchestratorURL: "payment-orchestrator:8444",
 		summaryServiceURL:      "summary-service:8445",
 		keyStore:               keyStore,
+		deduper:                deduper,
+		db:                     openGatewayDB(),
+	}
+	if earlyAck {
+		gateway.earlyAck = startEarlyAck(gateway)
 	}
 
 	// Create router
@@ -407,23 +812,63 @@ func main() {
 		w.Write([]byte(`{"status":"healthy"}`))
 	}).Methods("GET")
 
-	// Routes with optimized handlers
-	router.HandleFunc("/payments", func(w http.ResponseWriter, r *http.Request) {
-		gateway.handlePayments(w, r)
-	}).Methods("POST")
+	// Métricas: quantos pagamentos cada transporte para o orchestrator atendeu
+	// Métricas: formato Prometheus em /metrics, JSON dos contadores em /metrics/json
+	router.Handle("/metrics", metricsHandler(newMetricsRegistry())).Methods("GET")
+	router.HandleFunc("/metrics/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int64{
+			"requests":   atomic.LoadInt64(&requestCount),
+			"successes":  atomic.LoadInt64(&successCount),
+			"errors":     atomic.LoadInt64(&errorCount),
+			"timeouts":   atomic.LoadInt64(&timeoutCount),
+			"grpcServed": atomic.LoadInt64(&grpcServedCount),
+			"httpServed": atomic.LoadInt64(&httpServedCount),
+
+			"breakerTransitions": atomic.LoadInt64(&breakerTransitionCount),
+		})
+	}).Methods("GET")
 
-	router.HandleFunc("/payments-summary", func(w http.ResponseWriter, r *http.Request) {
-		gateway.handlePaymentsSummary(w, r)
+	// Estado do circuit breaker
+	router.HandleFunc("/circuit-breaker", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(circuitBreaker.Snapshot())
 	}).Methods("GET")
 
+	// Admin: força o breaker de volta para CLOSED
+	router.HandleFunc("/circuit-breaker/reset", func(w http.ResponseWriter, r *http.Request) {
+		circuitBreaker.Reset()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(circuitBreaker.Snapshot())
+	}).Methods("POST")
+
+	// Admin: entradas por cache em memória
+	router.HandleFunc("/admin/memory", cachereg.Handler).Methods("GET")
+
+	// Chaves públicas do gateway, para o orchestrator verificar assinaturas
+	router.HandleFunc("/.well-known/jwks.json", keys.JWKSHandler(keyStore)).Methods("GET")
+
+	// Routes with optimized handlers
+	router.HandleFunc("/payments", counted(gateway.handlePayments)).Methods("POST")
+
+	if gateway.earlyAck != nil {
+		router.HandleFunc("/payments/{id}", gateway.earlyAck.handlePaymentStatus).Methods("GET")
+	}
+
+	router.HandleFunc("/payments-summary", counted(gateway.handlePaymentsSummary)).Methods("GET")
+	router.HandleFunc("/purge-payments", gateway.handlePurgePayments).Methods("POST")
+
+	// Exportação para conciliação com os registros dos processors
+	router.HandleFunc("/export", gateway.handleExport).Methods("GET")
+
 	// Start server with BRUTO settings
 	server := &http.Server{
 		Addr:         ":9999",
-		Handler:      router,
+		Handler:      trackInFlight(router),
 		ReadTimeout:  100 * time.Millisecond, // BRUTO: 100ms
 		WriteTimeout: 100 * time.Millisecond, // BRUTO: 100ms
 		IdleTimeout:  30 * time.Second,
 	}
 
-	server.ListenAndServe()
+	runServer(server, gateway.db)
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Contadores de requisições das rotas públicas, expostos em /metrics
+var (
+	requestCount int64
+	successCount int64
+	errorCount   int64
+	timeoutCount int64 // chamadas ao orchestrator que estouraram o prazo
+)
+
+// statusRecorder guarda o status escrito pelo handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// counted conta a requisição e o desfecho pelo status: >= 400 é erro
+func counted(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requestCount, 1)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		if rec.status >= 400 {
+			atomic.AddInt64(&errorCount, 1)
+		} else {
+			atomic.AddInt64(&successCount, 1)
+		}
+	}
+}
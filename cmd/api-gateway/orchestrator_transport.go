@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+var (
+	// ORCHESTRATOR_TRANSPORT: http (padrão), grpc ou grpc-with-http-fallback
+	orchestratorTransport = config.GetString("ORCHESTRATOR_TRANSPORT", "http")
+
+	orchestratorHTTPURL = config.GetString("PAYMENT_ORCHESTRATOR_HTTP_URL", "http://payment-orchestrator:8444")
+
+	orchestratorHTTPClient = &http.Client{
+		Timeout: 300 * time.Millisecond,
+		Transport: &http.Transport{
+			MaxIdleConns:        1000,
+			MaxIdleConnsPerHost: 200,
+			IdleConnTimeout:     30 * time.Second,
+			DisableCompression:  true,
+		},
+	}
+
+	// Quantos pagamentos cada transporte atendeu
+	grpcServedCount int64
+	httpServedCount int64
+)
+
+// Mensagem da resposta recusada pelo breaker aberto, sem chamar o orchestrator
+const breakerOpenMessage = "Circuit breaker open"
+
+// callPaymentOrchestrator passa pelo circuit breaker do gateway: aberto, recusa
+// sem chamar o orchestrator; senão registra o resultado da chamada. Erros 4xx
+// são do pagamento, não do orchestrator, e contam como sucesso para o breaker.
+func (g *Gateway) callPaymentOrchestrator(paymentReq PaymentRequest) HTTPPaymentResponse {
+	allowed, probe := circuitBreaker.canExecute()
+	if !allowed {
+		return HTTPPaymentResponse{Status: "error", Message: breakerOpenMessage, statusCode: http.StatusServiceUnavailable}
+	}
+	resp := g.callPaymentOrchestratorTransport(paymentReq)
+	circuitBreaker.recordResult(probe, resp.Status != "error" || isClientError(resp.statusCode))
+	return resp
+}
+
+func isClientError(code int) bool {
+	return code >= 400 && code < 500
+}
+
+// callPaymentOrchestratorTransport escolhe o transporte conforme ORCHESTRATOR_TRANSPORT;
+// no modo com fallback, falha de conexão no gRPC cai para o HTTP
+func (g *Gateway) callPaymentOrchestratorTransport(paymentReq PaymentRequest) HTTPPaymentResponse {
+	switch orchestratorTransport {
+	case "grpc":
+		return g.callPaymentOrchestratorBRUTO(paymentReq)
+	case "grpc-with-http-fallback":
+		resp, err := g.callPaymentOrchestratorGRPC(paymentReq)
+		if status.Code(err) == codes.Unavailable {
+			return g.callPaymentOrchestratorHTTP(paymentReq)
+		}
+		return resp
+	default:
+		return g.callPaymentOrchestratorHTTP(paymentReq)
+	}
+}
+
+// BRUTO: Call Payment Orchestrator via HTTP
+func (g *Gateway) callPaymentOrchestratorHTTP(paymentReq PaymentRequest) HTTPPaymentResponse {
+	body, err := json.Marshal(paymentReq)
+	if err != nil {
+		return HTTPPaymentResponse{Status: "error", Message: "JSON marshal failed"}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", orchestratorHTTPURL+"/payments", bytes.NewReader(body))
+	if err != nil {
+		return HTTPPaymentResponse{Status: "error", Message: "Request creation failed"}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := orchestratorHTTPClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			atomic.AddInt64(&timeoutCount, 1)
+		}
+		return HTTPPaymentResponse{Status: "error", Message: "Orchestrator failed"}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return HTTPPaymentResponse{Status: "error", Message: fmt.Sprintf("Orchestrator returned %d", resp.StatusCode), statusCode: resp.StatusCode}
+	}
+
+	var result HTTPPaymentResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return HTTPPaymentResponse{Status: "error", Message: "Invalid orchestrator response"}
+	}
+	atomic.AddInt64(&httpServedCount, 1)
+	return result
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPaymentRequestBodyFields(t *testing.T) {
+	cases := []struct {
+		name    string
+		body    string
+		want    PaymentRequest
+		wantErr string
+	}{
+		{
+			name: "all present",
+			body: `{"correlationId":"a","amount":10.5,"customerId":"c-1","description":"Coffee"}`,
+			want: PaymentRequest{CorrelationID: "a", Amount: 10.5, CustomerID: "c-1", Description: "Coffee"},
+		},
+		{name: "correlationId absent", body: `{"amount":1}`, wantErr: "correlationId is required"},
+		{name: "correlationId null", body: `{"correlationId":null,"amount":1}`, wantErr: "correlationId is required"},
+		{name: "correlationId empty", body: `{"correlationId":"","amount":1}`, wantErr: "correlationId is required"},
+		{name: "amount absent", body: `{"correlationId":"a"}`, wantErr: "amount is required"},
+		{name: "amount null", body: `{"correlationId":"a","amount":null}`, wantErr: "amount is required"},
+		{name: "amount zero", body: `{"correlationId":"a","amount":0}`, wantErr: "amount must be positive"},
+		{name: "amount negative", body: `{"correlationId":"a","amount":-1}`, wantErr: "amount must be positive"},
+		{
+			name: "optional fields absent",
+			body: `{"correlationId":"a","amount":1}`,
+			want: PaymentRequest{CorrelationID: "a", Amount: 1, CustomerID: defaultCustomerID, Description: defaultDescription},
+		},
+		{
+			name: "optional fields null",
+			body: `{"correlationId":"a","amount":1,"customerId":null,"description":null}`,
+			want: PaymentRequest{CorrelationID: "a", Amount: 1, CustomerID: defaultCustomerID, Description: defaultDescription},
+		},
+		{
+			name: "optional fields empty",
+			body: `{"correlationId":"a","amount":1,"customerId":"","description":""}`,
+			want: PaymentRequest{CorrelationID: "a", Amount: 1, CustomerID: defaultCustomerID, Description: defaultDescription},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body paymentRequestBody
+			if err := json.Unmarshal([]byte(tc.body), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			got, err := body.toPaymentRequest()
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("err = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("request = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandlePaymentsRejectsNonStringCorrelationID(t *testing.T) {
+	withOrchestrator(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("invalid payment reached the orchestrator")
+	})
+	g := newTestGateway(t, false)
+
+	for _, body := range []string{
+		`{"correlationId":123,"amount":1}`,
+		`{"correlationId":true,"amount":1}`,
+		`{"correlationId":{"id":"x"},"amount":1}`,
+		`{"correlationId":"ok","amount":"10"}`,
+	} {
+		rec := postPayment(g, body)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want 400", body, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "must be a") {
+			t.Fatalf("%s: body %q does not name the expected type", body, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
+)
+
+// Banco do gateway (pagamentos cobrados e pendentes do early-ack); vazio, o
+// padrão, desliga. O BoltDB trava o arquivo com flock, então cada instância
+// precisa do seu próprio caminho (as réplicas compartilham ./data no compose).
+var gatewayDBPath = config.GetString("GATEWAY_DB_PATH", "")
+
+// openGatewayDB abre o banco uma vez por processo e o early-ack reaproveita a
+// mesma conexão. Só é chamado com um caminho configurado explicitamente.
+func openGatewayDB() *database.Database {
+	if gatewayDBPath == "" {
+		return nil
+	}
+	db, err := database.NewDatabase(gatewayDBPath)
+	if err != nil {
+		log.Fatalf("Failed to open gateway database: %v", err)
+	}
+	return db
+}
+
+// persistPayment grava o pagamento cobrado pelo processor; falha só é logada
+// porque a cobrança já aconteceu. RequestedAt é o mesmo instante enviado ao
+// processor, para o resumo bater com o dele; o gRPC não o transporta e fica zerado.
+func (g *Gateway) persistPayment(paymentReq PaymentRequest, result HTTPPaymentResponse) {
+	if g.db == nil {
+		return
+	}
+	now := time.Now()
+	var requestedAt time.Time
+	if result.RequestedAt != "" {
+		if at, err := time.Parse(time.RFC3339Nano, result.RequestedAt); err == nil {
+			requestedAt = at
+		}
+	}
+	err := g.db.CreatePayment(&database.Payment{
+		ID:            paymentReq.CorrelationID,
+		CustomerID:    paymentReq.CustomerID,
+		Amount:        paymentReq.Amount,
+		Description:   paymentReq.Description,
+		Status:        paymentCompleted,
+		ProcessorUsed: result.Processor,
+		RequestedAt:   requestedAt,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	})
+	if err != nil {
+		log.Printf("Failed to store payment %s: %v", paymentReq.CorrelationID, err)
+	}
+}
+
+// handleExport exporta os pagamentos gravados (GET /export?format=json|csv),
+// em streaming direto do banco
+func (g *Gateway) handleExport(w http.ResponseWriter, r *http.Request) {
+	if g.db == nil {
+		http.Error(w, "payments database disabled", http.StatusServiceUnavailable)
+		return
+	}
+	var err error
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		err = g.db.ExportJSON(w)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="payments.csv"`)
+		err = g.db.ExportCSV(w)
+	default:
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+		return
+	}
+	// Com o corpo já parcialmente enviado não dá para mudar o status; só loga
+	if err != nil {
+		log.Printf("Export failed: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/dedup"
+)
+
+// newTestGateway cria um gateway com dedup em memória e, se withDB, um banco
+// BoltDB temporário
+func newTestGateway(t *testing.T, withDB bool) *Gateway {
+	t.Helper()
+	g := &Gateway{deduper: dedup.NewMemory(dedup.Options{})}
+	if withDB {
+		db, err := database.NewDatabase(filepath.Join(t.TempDir(), "gateway.db"))
+		if err != nil {
+			t.Fatalf("NewDatabase: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		g.db = db
+	}
+	return g
+}
+
+// withOrchestrator aponta o transporte HTTP para um orchestrator falso
+func withOrchestrator(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	prevURL, prevTransport := orchestratorHTTPURL, orchestratorTransport
+	orchestratorHTTPURL, orchestratorTransport = server.URL, "http"
+	circuitBreaker.Reset()
+	t.Cleanup(func() {
+		server.Close()
+		orchestratorHTTPURL, orchestratorTransport = prevURL, prevTransport
+		circuitBreaker.Reset()
+	})
+}
+
+func postPayment(g *Gateway, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/payments", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	g.handlePayments(rec, req)
+	return rec
+}
+
+func TestHandlePaymentsPersistsProcessorCharge(t *testing.T) {
+	requestedAt := "2025-07-15T12:34:56.789Z"
+	withOrchestrator(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"p-1","status":"processed","message":"ok","processor":"fallback","requestedAt":"` + requestedAt + `"}`))
+	})
+	g := newTestGateway(t, true)
+
+	rec := postPayment(g, `{"correlationId":"p-1","amount":19.9,"customerId":"c-1"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", rec.Code, rec.Body)
+	}
+
+	p, err := g.db.GetPaymentByID("p-1")
+	if err != nil {
+		t.Fatalf("payment not stored: %v", err)
+	}
+	if p.ProcessorUsed != "fallback" || p.Amount != 19.9 || p.CustomerID != "c-1" || p.Status != paymentCompleted {
+		t.Fatalf("stored payment = %+v", p)
+	}
+	want, _ := time.Parse(time.RFC3339Nano, requestedAt)
+	if !p.RequestedAt.Equal(want) {
+		t.Fatalf("RequestedAt = %v, want %v", p.RequestedAt, want)
+	}
+}
+
+func TestHandlePaymentsOrchestratorFailure(t *testing.T) {
+	withOrchestrator(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	g := newTestGateway(t, true)
+
+	rec := postPayment(g, `{"correlationId":"p-2","amount":10}`)
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", rec.Code)
+	}
+	if _, err := g.db.GetPaymentByID("p-2"); err == nil {
+		t.Fatal("failed payment was stored")
+	}
+	// Sem cobrança, o cliente pode reenviar
+	if seen, _ := g.deduper.Seen("p-2"); seen {
+		t.Fatal("failed payment was marked as processed")
+	}
+}
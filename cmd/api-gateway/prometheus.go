@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// newMetricsRegistry registra contadores e estado do breaker num registry
+// próprio; os contadores leem os mesmos atômicos do /metrics/json
+func newMetricsRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	counters := map[string]*int64{
+		"requests":  &requestCount,
+		"successes": &successCount,
+		"errors":    &errorCount,
+		"timeouts":  &timeoutCount,
+	}
+	for name, counter := range counters {
+		registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "gateway_" + name + "_total",
+			Help: "Contador atômico " + name + " do gateway.",
+		}, func() float64 { return float64(atomic.LoadInt64(counter)) }))
+	}
+
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "gateway_circuit_breaker_state",
+		Help: "Estado do circuit breaker (0 CLOSED, 1 OPEN, 2 HALF_OPEN).",
+	}, func() float64 { return float64(circuitBreaker.currentState()) }))
+	return registry
+}
+
+// metricsHandler serve o registry no formato do Prometheus
+func metricsHandler(registry *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// handlePurgePayments apaga os pagamentos gravados, os IDs de idempotência e os
+// contadores do summary-service (contrato /purge-payments da Rinha)
+func (g *Gateway) handlePurgePayments(w http.ResponseWriter, r *http.Request) {
+	var failures []string
+	if g.db != nil {
+		if err := g.db.PurgeAllPayments(); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if err := g.deduper.Clear(); err != nil {
+		failures = append(failures, err.Error())
+	}
+	if err := callSummaryServicePurge(); err != nil {
+		failures = append(failures, err.Error())
+	}
+	brutoCache.Clear()
+	summaryRanges.forget()
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(failures) > 0 {
+		log.Printf("Purge incomplete: %v", failures)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"message": "Purge incomplete", "errors": failures})
+		return
+	}
+	w.Write([]byte(`{"message":"Payments purged"}`))
+}
+
+// callSummaryServicePurge zera os contadores do summary-service
+func callSummaryServicePurge() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", summaryServiceHTTPURL+"/purge", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := summaryHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("summary purge failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("summary service returned %d", resp.StatusCode)
+	}
+	return nil
+}
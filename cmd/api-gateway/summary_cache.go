@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+var (
+	// Por quanto tempo um resumo em cache pode ser servido
+	summaryCacheTTL = config.GetDuration("SUMMARY_CACHE_TTL", time.Second)
+
+	// Máximo de entradas distintas com from/to; o resumo sem parâmetros não conta
+	summaryCacheMaxRanges = config.GetInt("SUMMARY_CACHE_MAX_RANGES", 100)
+
+	summaryRanges = &summaryRangeKeys{keys: make(map[string]struct{})}
+)
+
+type cachedSummary struct {
+	summary  HTTPSummaryResponse
+	cachedAt time.Time
+}
+
+// summaryCacheKey monta a chave summary_<from>_<to>; sem parâmetros a chave é fixa
+func summaryCacheKey(from, to string) (key string, ranged bool) {
+	if from == "" && to == "" {
+		return "summary", false
+	}
+	return "summary_" + from + "_" + to, true
+}
+
+// Chaves com intervalo atualmente no brutoCache
+type summaryRangeKeys struct {
+	keys map[string]struct{}
+	mu   sync.Mutex
+}
+
+// getCachedSummary retorna o resumo em cache se ainda estiver dentro do TTL
+func getCachedSummary(key string) (HTTPSummaryResponse, bool) {
+	cached, ok := brutoCache.Get(key)
+	if !ok || time.Since(cached.cachedAt) > summaryCacheTTL {
+		return HTTPSummaryResponse{}, false
+	}
+	return cached.summary, true
+}
+
+// setCachedSummary guarda o resumo. Entradas com intervalo respeitam
+// SUMMARY_CACHE_MAX_RANGES: no limite, primeiro descarta intervalos já
+// expirados; se nenhum expirou, não guarda (em vez de despejar uma entrada quente)
+func setCachedSummary(key string, ranged bool, summary HTTPSummaryResponse) {
+	entry := cachedSummary{summary: summary, cachedAt: time.Now()}
+	if !ranged {
+		brutoCache.Set(key, entry, summaryCacheTTL)
+		return
+	}
+
+	summaryRanges.mu.Lock()
+	defer summaryRanges.mu.Unlock()
+	if _, ok := summaryRanges.keys[key]; !ok && len(summaryRanges.keys) >= summaryCacheMaxRanges {
+		summaryRanges.evictExpired()
+		if len(summaryRanges.keys) >= summaryCacheMaxRanges {
+			return
+		}
+	}
+	summaryRanges.keys[key] = struct{}{}
+	brutoCache.Set(key, entry, summaryCacheTTL)
+}
+
+// evictExpired remove do cache os intervalos fora do TTL; chamado com mu travado
+func (s *summaryRangeKeys) evictExpired() {
+	for key := range s.keys {
+		cached, ok := brutoCache.Get(key)
+		if !ok || time.Since(cached.cachedAt) > summaryCacheTTL {
+			brutoCache.Delete(key)
+			delete(s.keys, key)
+		}
+	}
+}
+
+// forget descarta o rastreio das chaves quando o brutoCache é limpo
+func (s *summaryRangeKeys) forget() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = make(map[string]struct{})
+}
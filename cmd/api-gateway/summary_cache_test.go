@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func withSummaryCache(t *testing.T, maxRanges int, ttl time.Duration) {
+	t.Helper()
+	prevMax, prevTTL := summaryCacheMaxRanges, summaryCacheTTL
+	summaryCacheMaxRanges, summaryCacheTTL = maxRanges, ttl
+	reset := func() {
+		brutoCache.Clear()
+		summaryRanges.forget()
+	}
+	reset()
+	t.Cleanup(func() {
+		summaryCacheMaxRanges, summaryCacheTTL = prevMax, prevTTL
+		reset()
+	})
+}
+
+func rangeKey(i int) string {
+	key, _ := summaryCacheKey(fmt.Sprintf("2025-07-15T12:%02d:00Z", i%60), fmt.Sprintf("2025-07-%02dT00:00:00Z", 16+i/60))
+	return key
+}
+
+func TestSummaryCacheBoundsDistinctRanges(t *testing.T) {
+	withSummaryCache(t, 10, time.Minute)
+
+	totalKey, _ := summaryCacheKey("", "")
+	setCachedSummary(totalKey, false, HTTPSummaryResponse{})
+	hot := rangeKey(0)
+	setCachedSummary(hot, true, HTTPSummaryResponse{})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 1; i <= 100; i++ {
+				setCachedSummary(rangeKey(g*100+i), true, HTTPSummaryResponse{})
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := len(summaryRanges.keys); n > summaryCacheMaxRanges {
+		t.Fatalf("tracked ranges = %d, want at most %d", n, summaryCacheMaxRanges)
+	}
+	if n := brutoCache.Len(); n > summaryCacheMaxRanges+1 {
+		t.Fatalf("cache entries = %d, want at most %d", n, summaryCacheMaxRanges+1)
+	}
+	// O resumo total e o intervalo quente não são despejados pela enxurrada
+	if _, ok := getCachedSummary(totalKey); !ok {
+		t.Fatal("unparameterized summary was evicted")
+	}
+	if _, ok := getCachedSummary(hot); !ok {
+		t.Fatal("hot range was evicted")
+	}
+
+	// No limite, um intervalo já em cache ainda é atualizado
+	setCachedSummary(hot, true, HTTPSummaryResponse{Default: ProcessorSummary{TotalRequests: 7}})
+	if s, _ := getCachedSummary(hot); s.Default.TotalRequests != 7 {
+		t.Fatalf("hot range not refreshed at the cap: %+v", s)
+	}
+}
+
+func TestSummaryCacheReusesExpiredRangeSlots(t *testing.T) {
+	withSummaryCache(t, 3, 20*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		setCachedSummary(rangeKey(i), true, HTTPSummaryResponse{})
+	}
+	// Cheio e tudo quente: o novo intervalo é calculado sem cache
+	setCachedSummary(rangeKey(3), true, HTTPSummaryResponse{})
+	if _, ok := getCachedSummary(rangeKey(3)); ok {
+		t.Fatal("range cached beyond the cap")
+	}
+
+	// Expirados liberam as vagas
+	time.Sleep(30 * time.Millisecond)
+	setCachedSummary(rangeKey(4), true, HTTPSummaryResponse{})
+	if _, ok := getCachedSummary(rangeKey(4)); !ok {
+		t.Fatal("range not cached after the old ones expired")
+	}
+	if n := len(summaryRanges.keys); n != 1 {
+		t.Fatalf("tracked ranges = %d, want 1 after evicting expired ones", n)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+var (
+	summaryServiceHTTPURL = config.GetString("SUMMARY_SERVICE_HTTP_URL", "http://summary-service:8445")
+
+	summaryHTTPClient = &http.Client{Timeout: 300 * time.Millisecond}
+)
+
+// parseSummaryRange valida from/to (ISO-8601); vazio significa sem limite
+func parseSummaryRange(from, to string) error {
+	if from != "" {
+		if _, err := time.Parse(time.RFC3339Nano, from); err != nil {
+			return fmt.Errorf("invalid from")
+		}
+	}
+	if to != "" {
+		if _, err := time.Parse(time.RFC3339Nano, to); err != nil {
+			return fmt.Errorf("invalid to")
+		}
+	}
+	return nil
+}
+
+// callSummaryServiceRange busca no summary-service o resumo de [from, to];
+// o gRPC GetSummary não tem campos de intervalo, então vai por HTTP
+func callSummaryServiceRange(from, to string) (HTTPSummaryResponse, error) {
+	query := url.Values{}
+	if from != "" {
+		query.Set("from", from)
+	}
+	if to != "" {
+		query.Set("to", to)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", summaryServiceHTTPURL+"/summary?"+query.Encode(), nil)
+	if err != nil {
+		return HTTPSummaryResponse{}, err
+	}
+	resp, err := summaryHTTPClient.Do(req)
+	if err != nil {
+		return HTTPSummaryResponse{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return HTTPSummaryResponse{}, fmt.Errorf("summary service returned %d", resp.StatusCode)
+	}
+
+	var summary HTTPSummaryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		return HTTPSummaryResponse{}, err
+	}
+	return summary, nil
+}
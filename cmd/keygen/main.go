@@ -0,0 +1,44 @@
+// keygen acrescenta um novo par ed25519 ao arquivo de chaves (config/keys.json),
+// para rotação de chaves sem editar o JSON à mão.
+//
+//	go run ./cmd/keygen -kid v2 [-file config/keys.json]
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"log"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/keys"
+)
+
+func main() {
+	kid := flag.String("kid", "", "kid da nova chave (obrigatório)")
+	path := flag.String("file", "config/keys.json", "arquivo de chaves")
+	flag.Parse()
+
+	if *kid == "" {
+		log.Fatal("-kid é obrigatório")
+	}
+
+	// Arquivo inexistente começa vazio
+	configs, err := keys.ReadKeyConfigs(*path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal(err)
+	}
+	for _, c := range configs {
+		if c.KID == *kid {
+			log.Fatalf("kid %s já existe em %s", *kid, *path)
+		}
+	}
+
+	key, err := keys.GenerateKey(*kid)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := keys.WriteKeysToFile(*path, append(configs, key)); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Chave %s adicionada a %s (publicKey %s)", *kid, *path, key.PublicKey)
+}
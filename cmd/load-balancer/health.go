@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+var (
+	// Intervalo entre health checks de um backend saudável
+	healthCheckInterval = config.GetDuration("LB_HEALTH_INTERVAL", 2*time.Second)
+	// Falhas consecutivas para tirar um backend de rotação
+	downThreshold = config.GetInt("LB_DOWN_THRESHOLD", 3)
+	// Sucessos consecutivos para um backend fora de rotação voltar
+	recoveryThreshold = config.GetInt("LB_RECOVERY_THRESHOLD", 2)
+	// Teto do backoff entre checagens de um backend fora de rotação
+	maxHealthBackoff = config.GetDuration("LB_HEALTH_MAX_BACKOFF", 30*time.Second)
+
+	healthClient = &http.Client{Timeout: 500 * time.Millisecond}
+
+	backendStates []*backendState
+)
+
+// Estado de saúde de um backend
+type backendState struct {
+	url      *url.URL
+	healthy  atomic.Bool
+	inFlight atomic.Int64 // requisições em andamento (least-connections)
+
+	// Métricas de /lb-metrics, uma contagem por tentativa no backend
+	requests     atomic.Int64
+	errors       atomic.Int64
+	latencyNanos atomic.Int64
+
+	mu                   sync.Mutex
+	consecutiveFailures  int
+	consecutiveSuccesses int
+	backoff              time.Duration
+	nextCheck            time.Time
+	lastCheck            time.Time
+	lastError            string
+}
+
+func newBackendState(u *url.URL) *backendState {
+	b := &backendState{url: u}
+	b.healthy.Store(true)
+	return b
+}
+
+// record aplica o resultado de um health check. Fora de rotação, cada falha
+// dobra o intervalo até a próxima checagem (até maxHealthBackoff)
+func (b *backendState) record(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastCheck = now
+
+	if err == nil {
+		b.consecutiveFailures = 0
+		b.consecutiveSuccesses++
+		b.lastError = ""
+		if !b.healthy.Load() && b.consecutiveSuccesses >= recoveryThreshold {
+			b.healthy.Store(true)
+			b.backoff = 0
+		}
+		b.nextCheck = now.Add(healthCheckInterval)
+		return
+	}
+
+	b.consecutiveSuccesses = 0
+	b.consecutiveFailures++
+	b.lastError = err.Error()
+	if b.consecutiveFailures >= downThreshold {
+		b.healthy.Store(false)
+	}
+	if b.healthy.Load() {
+		b.nextCheck = now.Add(healthCheckInterval)
+		return
+	}
+	if b.backoff == 0 {
+		b.backoff = healthCheckInterval
+	} else {
+		b.backoff *= 2
+	}
+	if b.backoff > maxHealthBackoff {
+		b.backoff = maxHealthBackoff
+	}
+	b.nextCheck = now.Add(b.backoff)
+}
+
+func (b *backendState) due(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !now.Before(b.nextCheck)
+}
+
+// Snapshot do estado para o endpoint admin
+type backendStatus struct {
+	Backend              string    `json:"backend"`
+	Healthy              bool      `json:"healthy"`
+	InFlight             int64     `json:"inFlight"`
+	ConsecutiveFailures  int       `json:"consecutiveFailures"`
+	ConsecutiveSuccesses int       `json:"consecutiveSuccesses"`
+	Backoff              string    `json:"backoff"`
+	NextCheck            time.Time `json:"nextCheck"`
+	LastCheck            time.Time `json:"lastCheck"`
+	LastError            string    `json:"lastError,omitempty"`
+}
+
+func (b *backendState) status() backendStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return backendStatus{
+		Backend:              b.url.String(),
+		Healthy:              b.healthy.Load(),
+		InFlight:             b.inFlight.Load(),
+		ConsecutiveFailures:  b.consecutiveFailures,
+		ConsecutiveSuccesses: b.consecutiveSuccesses,
+		Backoff:              b.backoff.String(),
+		NextCheck:            b.nextCheck,
+		LastCheck:            b.lastCheck,
+		LastError:            b.lastError,
+	}
+}
+
+// healthyBackends retorna os backends em rotação; com todos fora, retorna
+// todos para não descartar o tráfego
+func healthyBackends() []*backendState {
+	healthy := make([]*backendState, 0, len(backendStates))
+	for _, b := range backendStates {
+		if b.healthy.Load() {
+			healthy = append(healthy, b)
+		}
+	}
+	if len(healthy) == 0 {
+		return backendStates
+	}
+	return healthy
+}
+
+// checkBackend faz GET /health no backend
+func checkBackend(b *backendState) error {
+	resp, err := healthClient.Get(b.url.String() + "/health")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check retornou status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// runHealthChecks verifica periodicamente os backends cuja próxima checagem venceu, até stop fechar
+func runHealthChecks(stop <-chan struct{}) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			for _, b := range backendStates {
+				if b.due(now) {
+					b.record(checkBackend(b), time.Now())
+				}
+			}
+		}
+	}
+}
+
+// GET /admin/backends: estado de saúde por backend
+func handleBackendsStatus(w http.ResponseWriter, r *http.Request) {
+	statuses := make([]backendStatus, 0, len(backendStates))
+	for _, b := range backendStates {
+		statuses = append(statuses, b.status())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
@@ -1,104 +1,70 @@
 package main
 
 import (
-	"context"
-	"io"
+	"crypto/tls"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-	"sync/atomic"
 	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
 )
 
 var (
 	// Atomic counter for round-robin
 	currentBackend int32 = 0
 
-	// Backends - API Gateways
-	backends = []string{
+	// Backends - API Gateways (BACKENDS separado por vírgulas)
+	backends = config.GetList("BACKENDS", []string{
 		"http://api-gateway-1:9999",
 		"http://api-gateway-2:9999",
+	})
+
+	// Transport único do proxy, criado uma vez: o http.DefaultTransport mantém só
+	// 2 conexões ociosas por host, o que força novas conexões sob carga
+	backendHTTPTransport = &http.Transport{
+		MaxIdleConns:        1000,
+		MaxIdleConnsPerHost: 200,
+		IdleConnTimeout:     30 * time.Second,
+		DisableCompression:  true,
 	}
-	backendURLs []*url.URL
 )
 
-// Ultra-fast load balancer
-type LoadBalancer struct{}
-
-func (lb *LoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Get next backend (round-robin)
-	backend := lb.getNextBackend()
+// Versões TLS aceitas na borda; abaixo de 1.2 a inicialização é recusada
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
 
-	// Create request to backend
-	url := backend + r.URL.Path
-	if r.URL.RawQuery != "" {
-		url += "?" + r.URL.RawQuery
-	}
-	req, err := http.NewRequest(r.Method, url, r.Body)
-	if err != nil {
-		http.Error(w, "Internal Server Error", 500)
-		return
+// buildTLSConfig monta a configuração TLS da borda a partir de TLS_MIN_VERSION e TLS_CIPHER_SUITES
+func buildTLSConfig() (*tls.Config, error) {
+	minName := config.GetString("TLS_MIN_VERSION", "1.2")
+	minVersion, ok := tlsVersions[minName]
+	if !ok {
+		return nil, fmt.Errorf("TLS_MIN_VERSION inválida ou fraca demais: %q (aceitas: 1.2, 1.3)", minName)
 	}
 
-	// Copy headers
-	for name, values := range r.Header {
-		for _, value := range values {
-			req.Header.Add(name, value)
-		}
-	}
+	tlsConfig := &tls.Config{MinVersion: minVersion}
 
-	// Ultra-aggressive timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
-	defer cancel()
-	req = req.WithContext(ctx)
-
-	// Make request to backend
-	client := &http.Client{
-		Timeout: 500 * time.Millisecond,
-		Transport: &http.Transport{
-			MaxIdleConns:        1000,
-			MaxIdleConnsPerHost: 200,
-			IdleConnTimeout:     30 * time.Second,
-			DisableCompression:  true,
-		},
+	// Sem lista configurada, usa as suítes seguras padrão do Go
+	names := config.GetList("TLS_CIPHER_SUITES", nil)
+	if len(names) == 0 {
+		return tlsConfig, nil
 	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		// Try next backend on failure
-		backend = lb.getNextBackend()
-		req.URL.Host = backend
-		resp, err = client.Do(req)
-		if err != nil {
-			http.Error(w, "Service Unavailable", 503)
-			return
-		}
+	secure := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		secure[suite.Name] = suite.ID
 	}
-	defer resp.Body.Close()
-
-	// Copy response headers
-	for name, values := range resp.Header {
-		for _, value := range values {
-			w.Header().Add(name, value)
+	for _, name := range names {
+		id, ok := secure[name]
+		if !ok {
+			return nil, fmt.Errorf("cipher suite desconhecida ou insegura: %s", name)
 		}
+		tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
 	}
-
-	// Set status code
-	w.WriteHeader(resp.StatusCode)
-
-	// Copy response body
-	io.Copy(w, resp.Body)
-}
-
-func (lb *LoadBalancer) getNextBackend() string {
-	next := atomic.AddInt32(&currentBackend, 1)
-	return backends[next%int32(len(backends))]
-}
-
-func getNextBackend() *url.URL {
-	next := atomic.AddInt32(&currentBackend, 1)
-	return backendURLs[next%int32(len(backendURLs))]
+	return tlsConfig, nil
 }
 
 func main() {
@@ -106,14 +72,28 @@ func main() {
 	for _, b := range backends {
 		u, err := url.Parse(b)
 		if err != nil {
-			log.Fatalf("Erro ao parsear backend: %v", err)
+			log.Fatalf("BACKENDS: backend inválido %q: %v", b, err)
 		}
-		backendURLs = append(backendURLs, u)
+		if u.Scheme == "" || u.Host == "" {
+			log.Fatalf("BACKENDS: backend inválido %q: esperado scheme://host:porta", b)
+		}
+		backendStates = append(backendStates, newBackendState(u))
+	}
+
+	if lbStrategy != "round-robin" && lbStrategy != "least-connections" && lbStrategy != "sticky" {
+		log.Fatalf("LB_STRATEGY inválida: %q (aceitas: round-robin, least-connections, sticky)", lbStrategy)
 	}
+	log.Printf("Backends: %v (estratégia %s)", backends, lbStrategy)
+
+	// Health checks ativos com backoff para backends fora de rotação
+	stopHealthChecks := make(chan struct{})
+	go runHealthChecks(stopHealthChecks)
 
 	proxy := &httputil.ReverseProxy{
+		// Falha de conexão ou 5xx tenta o próximo backend (ver retryTransport)
+		Transport: &retryTransport{base: &backendTransport{base: backendHTTPTransport}, retries: lbMaxRetries},
 		Director: func(req *http.Request) {
-			backend := getNextBackend()
+			backend := pickBackendFor(req).url
 			req.URL.Scheme = backend.Scheme
 			req.URL.Host = backend.Host
 			// O Path já está correto
@@ -126,11 +106,31 @@ func main() {
 		},
 	}
 
+	// Endpoints admin do próprio load balancer; o resto vai para o proxy
+	handler := http.NewServeMux()
+	handler.HandleFunc("/admin/backends", handleBackendsStatus)
+	handler.HandleFunc("/lb-metrics", handleLBMetrics)
+	handler.Handle("/", proxy)
+
 	server := &http.Server{
 		Addr:    ":9999",
-		Handler: proxy,
+		Handler: handler,
+	}
+
+	// TLS na borda: habilitado quando certificado e chave são configurados
+	certFile := config.GetString("TLS_CERT_FILE", "")
+	keyFile := config.GetString("TLS_KEY_FILE", "")
+	if certFile != "" && keyFile != "" {
+		tlsConfig, err := buildTLSConfig()
+		if err != nil {
+			log.Fatalf("Erro na configuração TLS: %v", err)
+		}
+		server.TLSConfig = tlsConfig
+		log.Printf("Load Balancer idiomático Go iniciando na porta 9999 (TLS)")
+		runServer(server, func() error { return server.ListenAndServeTLS(certFile, keyFile) }, func() { close(stopHealthChecks) })
+		return
 	}
 
 	log.Printf("Load Balancer idiomático Go iniciando na porta 9999")
-	log.Fatal(server.ListenAndServe())
+	runServer(server, server.ListenAndServe, func() { close(stopHealthChecks) })
 }
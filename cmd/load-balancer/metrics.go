@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// backendTransport conta, por backend, as requisições em andamento
+// (least-connections) e as métricas de /lb-metrics
+type backendTransport struct {
+	base http.RoundTripper
+}
+
+func (t *backendTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b := backendFor(req.URL.Host)
+	if b == nil {
+		return t.base.RoundTrip(req)
+	}
+	b.inFlight.Add(1)
+	defer b.inFlight.Add(-1)
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	b.latencyNanos.Add(int64(time.Since(start)))
+	b.requests.Add(1)
+	if err != nil || resp.StatusCode >= 500 {
+		b.errors.Add(1)
+	}
+	return resp, err
+}
+
+// Métricas de um backend em /lb-metrics
+type backendMetrics struct {
+	Backend        string  `json:"backend"`
+	Requests       int64   `json:"requests"`
+	Errors         int64   `json:"errors"`
+	TotalLatencyMs float64 `json:"totalLatencyMs"`
+	AvgLatencyMs   float64 `json:"avgLatencyMs"`
+	InFlight       int64   `json:"inFlight"`
+}
+
+// GET /lb-metrics: divisão do tráfego entre os backends
+func handleLBMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics := make([]backendMetrics, 0, len(backendStates))
+	for _, b := range backendStates {
+		m := backendMetrics{
+			Backend:        b.url.String(),
+			Requests:       b.requests.Load(),
+			Errors:         b.errors.Load(),
+			TotalLatencyMs: float64(b.latencyNanos.Load()) / float64(time.Millisecond),
+			InFlight:       b.inFlight.Load(),
+		}
+		if m.Requests > 0 {
+			m.AvgLatencyMs = m.TotalLatencyMs / float64(m.Requests)
+		}
+		metrics = append(metrics, m)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metrics)
+}
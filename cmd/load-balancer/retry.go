@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+// Retentativas em outros backends após a primeira tentativa (0 desliga)
+var lbMaxRetries = config.GetInt("LB_MAX_RETRIES", 1)
+
+// retryTransport reenvia o request ao próximo backend em rotação só quando a
+// conexão falhou antes de qualquer byte chegar ao backend (dial recusado, reset
+// antes dos headers). Respostas, inclusive 5xx, passam adiante: o backend pode
+// já ter cobrado o pagamento, e reenviar um POST arriscaria cobrança dupla.
+// O body é lido uma vez e cada tentativa recebe um leitor novo sobre os mesmos bytes.
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tried := map[string]bool{}
+	attempt := req
+	for retry := 0; ; retry++ {
+		tried[attempt.URL.Host] = true
+		if body != nil {
+			attempt.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		var wrote atomic.Bool
+		trace := &httptrace.ClientTrace{WroteHeaders: func() { wrote.Store(true) }}
+		resp, err := t.base.RoundTrip(attempt.WithContext(httptrace.WithClientTrace(attempt.Context(), trace)))
+		if err == nil || wrote.Load() {
+			return resp, err
+		}
+
+		var next *backendState
+		if retry < t.retries {
+			next = untriedBackend(tried)
+		}
+		if next == nil {
+			return nil, err
+		}
+		attempt = req.Clone(req.Context())
+		attempt.URL.Scheme = next.url.Scheme
+		attempt.URL.Host = next.url.Host
+	}
+}
+
+// untriedBackend retorna um backend em rotação ainda não tentado, ou nil
+func untriedBackend(tried map[string]bool) *backendState {
+	for _, b := range healthyBackends() {
+		if !tried[b.url.Host] {
+			return b
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// withBackends troca os backends em rotação pelos servidores informados
+func withBackends(t *testing.T, urls ...string) {
+	t.Helper()
+	prev := backendStates
+	backendStates = nil
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backendStates = append(backendStates, newBackendState(u))
+	}
+	t.Cleanup(func() { backendStates = prev })
+}
+
+func postTo(t *testing.T, rt http.RoundTripper, target, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest("POST", target+"/payments", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestRetryTransportPassesThrough5xx(t *testing.T) {
+	var failingHits, healthyHits atomic.Int64
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failingHits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthyHits.Add(1)
+	}))
+	defer healthy.Close()
+	withBackends(t, failing.URL, healthy.URL)
+
+	rt := &retryTransport{base: http.DefaultTransport, retries: 1}
+	resp := postTo(t, rt, failing.URL, `{"correlationId":"a","amount":1}`)
+
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want the upstream 500", resp.StatusCode)
+	}
+	// O backend pode ter cobrado o pagamento: não reenvia
+	if failingHits.Load() != 1 || healthyHits.Load() != 0 {
+		t.Fatalf("hits failing=%d healthy=%d, want 1 and 0", failingHits.Load(), healthyHits.Load())
+	}
+}
+
+func TestRetryTransportRetriesConnectionErrorOnce(t *testing.T) {
+	// Backend fora do ar: o dial é recusado antes de qualquer byte sair
+	dead := httptest.NewServer(http.NotFoundHandler())
+	deadURL := dead.URL
+	dead.Close()
+
+	var received atomic.Value
+	var healthyHits atomic.Int64
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthyHits.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		received.Store(string(body))
+	}))
+	defer healthy.Close()
+	withBackends(t, deadURL, healthy.URL)
+
+	payload := `{"correlationId":"b","amount":19.9}`
+	rt := &retryTransport{base: http.DefaultTransport, retries: 1}
+	resp := postTo(t, rt, deadURL, payload)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200 from the retried backend", resp.StatusCode)
+	}
+	if healthyHits.Load() != 1 {
+		t.Fatalf("healthy backend hits = %d, want 1", healthyHits.Load())
+	}
+	if got, _ := received.Load().(string); got != payload {
+		t.Fatalf("retried body = %q, want %q", got, payload)
+	}
+}
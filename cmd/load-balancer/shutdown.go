@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+// Tempo máximo para drenar as requisições em andamento
+var shutdownTimeout = config.GetDuration("SHUTDOWN_TIMEOUT", 10*time.Second)
+
+// runServer serve até SIGINT/SIGTERM; então drena as requisições em andamento
+// e para os health checks. listen é ListenAndServe ou ListenAndServeTLS.
+func runServer(server *http.Server, listen func() error, stopHealthChecks func()) {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- listen() }()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			// Porta ocupada, certificado inválido etc.: não há o que servir
+			stopHealthChecks()
+			log.Fatalf("Server failed: %v", err)
+		}
+	case sig := <-stop:
+		log.Printf("Received %s, shutting down", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Drain incomplete: %v", err)
+		}
+	}
+
+	stopHealthChecks()
+	log.Printf("Load Balancer stopped")
+}
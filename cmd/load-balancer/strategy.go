@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+// Estratégia de balanceamento: round-robin (padrão), least-connections ou
+// sticky (mesmo correlationId sempre no mesmo gateway, para o dedup ficar local)
+var lbStrategy = config.GetString("LB_STRATEGY", "round-robin")
+
+// Quanto do body o modo sticky lê para achar o correlationId; o resto segue
+// direto para o backend sem passar pela memória do LB
+var stickyBodyPeekBytes = int64(config.GetInt("LB_STICKY_BODY_PEEK_BYTES", 4096))
+
+// pickBackendFor escolhe o backend do request; no modo sticky usa o correlationId
+// (header X-Correlation-Id ou body JSON), senão cai em pickBackend
+func pickBackendFor(req *http.Request) *backendState {
+	if lbStrategy == "sticky" {
+		if id := correlationID(req); id != "" {
+			return backendByHash(id)
+		}
+	}
+	return pickBackend()
+}
+
+// correlationID lê o id do header X-Correlation-Id; sem header, lê no máximo
+// stickyBodyPeekBytes do body JSON e o recoloca inteiro no request para o proxy
+// reenviar (o trecho lido seguido do que ficou no body original)
+func correlationID(req *http.Request) string {
+	if id := req.Header.Get("X-Correlation-Id"); id != "" {
+		return id
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return ""
+	}
+	original := req.Body
+	body, err := io.ReadAll(io.LimitReader(original, stickyBodyPeekBytes))
+	req.Body = peekedBody{Reader: io.MultiReader(bytes.NewReader(body), original), Closer: original}
+	if err != nil {
+		return ""
+	}
+	var payload struct {
+		CorrelationID string `json:"correlationId"`
+	}
+	// Body maior que o limite fica truncado e não decodifica: cai no round-robin
+	json.Unmarshal(body, &payload)
+	return payload.CorrelationID
+}
+
+// peekedBody devolve o trecho já lido antes do restante e fecha o body original
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// backendByHash escolhe por rendezvous hashing (HRW) entre os backends em
+// rotação: um backend fora só remaneja os ids que o tinham como preferido
+func backendByHash(key string) *backendState {
+	var best *backendState
+	var bestScore uint64
+	for _, b := range healthyBackends() {
+		h := fnv.New64a()
+		h.Write([]byte(b.url.Host))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = b, score
+		}
+	}
+	return best
+}
+
+// pickBackend escolhe o próximo backend entre os em rotação segundo LB_STRATEGY
+func pickBackend() *backendState {
+	candidates := healthyBackends()
+	if lbStrategy == "least-connections" {
+		// Menos requisições em andamento; empate fica com o primeiro da lista
+		best := candidates[0]
+		for _, b := range candidates[1:] {
+			if b.inFlight.Load() < best.inFlight.Load() {
+				best = b
+			}
+		}
+		return best
+	}
+	next := atomic.AddInt32(&currentBackend, 1)
+	return candidates[uint32(next)%uint32(len(candidates))]
+}
+
+// backendFor retorna o estado do backend pelo host, ou nil se desconhecido
+func backendFor(host string) *backendState {
+	for _, b := range backendStates {
+		if b.url.Host == host {
+			return b
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCorrelationIDRestoresBody(t *testing.T) {
+	prev := stickyBodyPeekBytes
+	stickyBodyPeekBytes = 64
+	t.Cleanup(func() { stickyBodyPeekBytes = prev })
+
+	small := `{"correlationId":"abc","amount":1}`
+	large := `{"amount":1,"padding":"` + strings.Repeat("x", 256) + `","correlationId":"late"}`
+	cases := []struct {
+		name, body, want string
+	}{
+		{"within limit", small, "abc"},
+		{"beyond limit", large, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "http://lb/payments", strings.NewReader(tc.body))
+			if got := correlationID(req); got != tc.want {
+				t.Fatalf("correlationID = %q, want %q", got, tc.want)
+			}
+			forwarded, _ := io.ReadAll(req.Body)
+			if string(forwarded) != tc.body {
+				t.Fatalf("forwarded body = %q, want the original %q", forwarded, tc.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newCountingBackend sobe um backend que conta as conexões TCP aceitas
+func newCountingBackend(tb testing.TB) (*httptest.Server, *atomic.Int64) {
+	tb.Helper()
+	var conns atomic.Int64
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	backend.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	backend.Start()
+	tb.Cleanup(backend.Close)
+	return backend, &conns
+}
+
+func get(tb testing.TB, client *http.Client, url string) {
+	tb.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+func TestSharedTransportReusesConnections(t *testing.T) {
+	backend, conns := newCountingBackend(t)
+	client := &http.Client{Transport: backendHTTPTransport}
+	for i := 0; i < 20; i++ {
+		get(t, client, backend.URL)
+	}
+	if n := conns.Load(); n != 1 {
+		t.Fatalf("backend accepted %d connections, want 1 reused keep-alive", n)
+	}
+}
+
+// BenchmarkPerRequestClient reproduz o handler antigo: um http.Client com
+// Transport novo a cada request, sem reaproveitar conexões
+func BenchmarkPerRequestClient(b *testing.B) {
+	backend, conns := newCountingBackend(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		transport := &http.Transport{}
+		get(b, &http.Client{Transport: transport}, backend.URL)
+		// Sem isso o benchmark esgota os descritores com as conexões vazadas
+		transport.CloseIdleConnections()
+	}
+	b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+}
+
+func BenchmarkSharedTransport(b *testing.B) {
+	backend, conns := newCountingBackend(b)
+	client := &http.Client{Transport: backendHTTPTransport}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		get(b, client, backend.URL)
+	}
+	b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+}
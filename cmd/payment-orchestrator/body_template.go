@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+// Campos estáticos (JSON objeto em PROCESSOR_BODY_TEMPLATE) mesclados em todo
+// corpo enviado aos processors, ex.: {"merchantId":"abc","apiVersion":"2"}
+var processorBodyTemplate map[string]interface{}
+
+// Campos do pagamento que o template nunca sobrescreve
+var coreProcessorFields = map[string]bool{
+	"correlationId": true,
+	"amount":        true,
+	"requestedAt":   true,
+}
+
+// loadProcessorBodyTemplate valida o template na inicialização
+func loadProcessorBodyTemplate() error {
+	raw := config.GetString("PROCESSOR_BODY_TEMPLATE", "")
+	if raw == "" {
+		return nil
+	}
+	var template map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &template); err != nil {
+		return fmt.Errorf("PROCESSOR_BODY_TEMPLATE must be a JSON object: %w", err)
+	}
+	for field := range template {
+		if coreProcessorFields[field] {
+			return fmt.Errorf("PROCESSOR_BODY_TEMPLATE cannot set core field %q", field)
+		}
+	}
+	processorBodyTemplate = template
+	return nil
+}
+
+// applyBodyTemplate retorna o corpo de saída: campos do cliente, depois os do
+// template, sem tocar nos campos do pagamento
+func applyBodyTemplate(paymentReq map[string]interface{}) map[string]interface{} {
+	if len(processorBodyTemplate) == 0 {
+		return paymentReq
+	}
+	body := make(map[string]interface{}, len(paymentReq)+len(processorBodyTemplate))
+	for k, v := range paymentReq {
+		body[k] = v
+	}
+	for k, v := range processorBodyTemplate {
+		if !coreProcessorFields[k] {
+			body[k] = v
+		}
+	}
+	return body
+}
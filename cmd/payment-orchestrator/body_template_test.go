@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func withBodyTemplate(t *testing.T, raw string) error {
+	t.Helper()
+	prev := processorBodyTemplate
+	t.Cleanup(func() { processorBodyTemplate = prev })
+	processorBodyTemplate = nil
+	t.Setenv("PROCESSOR_BODY_TEMPLATE", raw)
+	return loadProcessorBodyTemplate()
+}
+
+func TestBodyTemplateAddsStaticFields(t *testing.T) {
+	if err := withBodyTemplate(t, `{"merchantId":"m-42","apiVersion":"2"}`); err != nil {
+		t.Fatal(err)
+	}
+	paymentReq := map[string]interface{}{
+		"correlationId": "t-1",
+		"amount":        19.9,
+		"requestedAt":   "2025-07-15T12:00:00.000Z",
+		"customerId":    "c-1",
+	}
+
+	data, err := json.Marshal(applyBodyTemplate(paymentReq))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	json.Unmarshal(data, &out)
+
+	want := map[string]interface{}{
+		"correlationId": "t-1",
+		"amount":        19.9,
+		"requestedAt":   "2025-07-15T12:00:00.000Z",
+		"customerId":    "c-1",
+		"merchantId":    "m-42",
+		"apiVersion":    "2",
+	}
+	for k, v := range want {
+		if out[k] != v {
+			t.Fatalf("outbound %s = %v, want %v (%s)", k, out[k], v, data)
+		}
+	}
+	// O pedido original não é alterado
+	if _, ok := paymentReq["merchantId"]; ok {
+		t.Fatal("template leaked into the client's payment request")
+	}
+}
+
+func TestBodyTemplateCannotClobberCoreFields(t *testing.T) {
+	for _, raw := range []string{`{"amount":0}`, `{"correlationId":"x"}`, `{"requestedAt":"never"}`} {
+		if err := withBodyTemplate(t, raw); err == nil {
+			t.Fatalf("template %s accepted at startup", raw)
+		}
+	}
+	if err := withBodyTemplate(t, `["not","an","object"]`); err == nil {
+		t.Fatal("non-object template accepted")
+	}
+
+	// Mesmo que um template com campos centrais chegue em memória, eles são ignorados
+	processorBodyTemplate = map[string]interface{}{"amount": 0.0, "correlationId": "x", "requestedAt": "never", "merchantId": "m"}
+	body := applyBodyTemplate(map[string]interface{}{"correlationId": "t-2", "amount": 5.0, "requestedAt": "now"})
+	if body["amount"] != 5.0 || body["correlationId"] != "t-2" || body["requestedAt"] != "now" || body["merchantId"] != "m" {
+		t.Fatalf("outbound body = %v", body)
+	}
+}
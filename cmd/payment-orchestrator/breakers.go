@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Breakers por processor, criados sob demanda com os parâmetros CB_*
+var (
+	processorBreakers   = make(map[string]*CircuitBreaker)
+	processorBreakersMu sync.RWMutex
+)
+
+// breakerFor retorna o breaker do processor, criando-o no primeiro uso
+func breakerFor(processor string) *CircuitBreaker {
+	processorBreakersMu.RLock()
+	cb, ok := processorBreakers[processor]
+	processorBreakersMu.RUnlock()
+	if ok {
+		return cb
+	}
+
+	processorBreakersMu.Lock()
+	defer processorBreakersMu.Unlock()
+	if cb, ok := processorBreakers[processor]; ok {
+		return cb
+	}
+	cb = newConfiguredBreaker(processor)
+	processorBreakers[processor] = cb
+	return cb
+}
+
+// GET /circuit-breaker: breaker geral no topo, breakers por processor em "processors"
+func handleCircuitBreaker(w http.ResponseWriter, r *http.Request) {
+	processorBreakersMu.RLock()
+	processors := make(map[string]CircuitBreakerSnapshot, len(processorBreakers))
+	for processor, cb := range processorBreakers {
+		processors[processor] = cb.Snapshot()
+	}
+	processorBreakersMu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		CircuitBreakerSnapshot
+		Processors map[string]CircuitBreakerSnapshot `json:"processors"`
+	}{circuitBreaker.Snapshot(), processors})
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+// Orçamento total de um pagamento: health check + chamada ao processor (e
+// retentativas) compartilham esse prazo em vez de cada um ter o seu
+var requestBudget = config.GetDuration("REQUEST_BUDGET", 500*time.Millisecond)
+
+// newRequestBudget cria o contexto com o orçamento do pagamento a partir do
+// contexto do request: vale o menor entre o prazo do próprio cliente e
+// REQUEST_BUDGET. O cancel deve ser chamado quando a estratégia terminar.
+func newRequestBudget(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), requestBudget)
+}
+
+// downstreamContext limita uma chamada downstream a max, sem nunca ultrapassar
+// o que resta do orçamento do contexto pai (o menor prazo prevalece)
+func downstreamContext(parent context.Context, max time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, max)
+}
+
+// countBudgetTimeout conta em timeoutCount um pagamento cujo orçamento estourou;
+// deve rodar antes do cancel do orçamento (que também preenche ctx.Err)
+func countBudgetTimeout(ctx context.Context) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		atomic.AddInt64(&timeoutCount, 1)
+	}
+}
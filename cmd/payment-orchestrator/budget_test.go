@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// remaining é o tempo até o prazo do contexto
+func remaining(t *testing.T, ctx context.Context) time.Duration {
+	t.Helper()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatal("context has no deadline")
+	}
+	return time.Until(deadline)
+}
+
+func TestDownstreamContextShrinksWithRequestAge(t *testing.T) {
+	prev := requestBudget
+	requestBudget = 200 * time.Millisecond
+	t.Cleanup(func() { requestBudget = prev })
+
+	budget, cancel := newRequestBudget(httptest.NewRequest("POST", "/payments", nil))
+	defer cancel()
+	budgetDeadline, _ := budget.Deadline()
+
+	// Limite da chamada acima do orçamento: vale o prazo do orçamento
+	first, cancelFirst := downstreamContext(budget, 300*time.Millisecond)
+	defer cancelFirst()
+	if d, _ := first.Deadline(); !d.Equal(budgetDeadline) {
+		t.Fatalf("downstream deadline = %v, want the budget deadline %v", d, budgetDeadline)
+	}
+	before := remaining(t, first)
+
+	time.Sleep(60 * time.Millisecond)
+
+	second, cancelSecond := downstreamContext(budget, 300*time.Millisecond)
+	defer cancelSecond()
+	after := remaining(t, second)
+	if after >= before-50*time.Millisecond {
+		t.Fatalf("downstream timeout went from %v to %v, want it to shrink as the request ages", before, after)
+	}
+	if after > requestBudget {
+		t.Fatalf("downstream timeout %v exceeds the budget %v", after, requestBudget)
+	}
+
+	// Limite menor que o restante: vale o limite da chamada
+	short, cancelShort := downstreamContext(budget, 10*time.Millisecond)
+	defer cancelShort()
+	if r := remaining(t, short); r > 10*time.Millisecond {
+		t.Fatalf("short call timeout = %v, want at most 10ms", r)
+	}
+}
+
+func TestDownstreamContextExpiredBudget(t *testing.T) {
+	prev := requestBudget
+	requestBudget = 20 * time.Millisecond
+	t.Cleanup(func() { requestBudget = prev })
+
+	budget, cancel := newRequestBudget(httptest.NewRequest("POST", "/payments", nil))
+	defer cancel()
+	<-budget.Done()
+
+	ctx, cancelCall := downstreamContext(budget, 300*time.Millisecond)
+	defer cancelCall()
+	if ctx.Err() == nil {
+		t.Fatal("downstream call started with the budget already spent")
+	}
+}
+
+func TestRequestBudgetHonorsInboundDeadline(t *testing.T) {
+	prev := requestBudget
+	requestBudget = 500 * time.Millisecond
+	t.Cleanup(func() { requestBudget = prev })
+
+	// Cliente com prazo menor que REQUEST_BUDGET: o prazo dele prevalece
+	parent, cancelParent := context.WithTimeout(context.Background(), 80*time.Millisecond)
+	defer cancelParent()
+	parentDeadline, _ := parent.Deadline()
+	req := httptest.NewRequest("POST", "/payments", nil).WithContext(parent)
+
+	budget, cancel := newRequestBudget(req)
+	defer cancel()
+	if d, _ := budget.Deadline(); !d.Equal(parentDeadline) {
+		t.Fatalf("budget deadline = %v, want the inbound deadline %v", d, parentDeadline)
+	}
+	call, cancelCall := downstreamContext(budget, 300*time.Millisecond)
+	defer cancelCall()
+	if r := remaining(t, call); r > 80*time.Millisecond {
+		t.Fatalf("downstream timeout = %v, want at most the 80ms left on the inbound request", r)
+	}
+
+	// Cliente sem prazo (ou com prazo maior): vale REQUEST_BUDGET
+	budget, cancel = newRequestBudget(httptest.NewRequest("POST", "/payments", nil))
+	defer cancel()
+	if r := remaining(t, budget); r > requestBudget || r < requestBudget-50*time.Millisecond {
+		t.Fatalf("budget = %v, want about %v", r, requestBudget)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// tripToHalfOpen abre o breaker e espera o openTimeout para a próxima chamada virar sonda
+func tripToHalfOpen(t *testing.T, cb *CircuitBreaker) {
+	t.Helper()
+	for i := 0; i < cb.threshold; i++ {
+		cb.recordOutcome(false)
+	}
+	if state := cb.currentState(); state != OPEN {
+		t.Fatalf("state after %d failures = %s, want OPEN", cb.threshold, state)
+	}
+	time.Sleep(cb.openTimeout + time.Millisecond)
+}
+
+func TestCircuitBreakerConcurrentHalfOpen(t *testing.T) {
+	cb := NewCircuitBreaker(3, time.Millisecond, nil).withMaxProbes(2)
+	tripToHalfOpen(t, cb)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				allowed, probe := cb.canExecute()
+				switch {
+				case allowed:
+					cb.recordResult(probe, (g+i)%3 != 0)
+				case i%2 == 0:
+					cb.recordFailure()
+				default:
+					cb.recordSuccess()
+				}
+				_ = cb.Snapshot()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if state := cb.currentState(); state != CLOSED && state != OPEN && state != HALF_OPEN {
+		t.Fatalf("invalid state %d", state)
+	}
+	if n := cb.probesInFlight.Load(); n < 0 || int(n) > cb.maxProbes {
+		t.Fatalf("probes in flight = %d, want within [0, %d]", n, cb.maxProbes)
+	}
+}
+
+func TestCircuitBreakerHalfOpenAdmitsMaxProbes(t *testing.T) {
+	cb := NewCircuitBreaker(1, 50*time.Millisecond, nil).withMaxProbes(3)
+	tripToHalfOpen(t, cb)
+
+	admitted := 0
+	for i := 0; i < 10; i++ {
+		allowed, probe := cb.canExecute()
+		if allowed != probe {
+			t.Fatalf("call %d: allowed=%v probe=%v, every HALF_OPEN call must be a probe", i, allowed, probe)
+		}
+		if allowed {
+			admitted++
+		}
+	}
+	if admitted != 3 {
+		t.Fatalf("admitted probes = %d, want 3", admitted)
+	}
+
+	// Sonda que não chegou ao downstream devolve a vaga
+	cb.releaseProbe()
+	if allowed, _ := cb.canExecute(); !allowed {
+		t.Fatal("released probe slot was not reused")
+	}
+	if allowed, _ := cb.canExecute(); allowed {
+		t.Fatal("admitted a probe beyond the limit")
+	}
+
+	cb.recordResult(true, true)
+	if state := cb.currentState(); state != CLOSED {
+		t.Fatalf("state after successful probe = %s, want CLOSED", state)
+	}
+}
+
+func TestCircuitBreakerWindowOpensOnFailureRatio(t *testing.T) {
+	// 60% de falhas, nunca mais de duas seguidas
+	stream := []bool{false, false, true, false, true}
+
+	consecutive := NewCircuitBreaker(5, time.Minute, nil)
+	windowed := NewCircuitBreaker(5, time.Minute, nil).withWindow(20, 0.5)
+	for i := 0; i < 40; i++ {
+		success := stream[i%len(stream)]
+		consecutive.recordOutcome(success)
+		if windowed.currentState() == CLOSED {
+			windowed.recordOutcome(success)
+		}
+		// A janela só decide quando cheia
+		if i < 19 && windowed.currentState() != CLOSED {
+			t.Fatalf("window opened after %d outcomes, before it was full", i+1)
+		}
+	}
+
+	if state := consecutive.currentState(); state != CLOSED {
+		t.Fatalf("consecutive counter state = %s, want CLOSED", state)
+	}
+	if state := windowed.currentState(); state != OPEN {
+		t.Fatalf("sliding window state = %s, want OPEN", state)
+	}
+}
+
+func TestCircuitBreakerWindowToleratesLowFailureRatio(t *testing.T) {
+	// 40% de falhas fica abaixo do limite de 50%
+	stream := []bool{false, true, false, true, true}
+	cb := NewCircuitBreaker(5, time.Minute, nil).withWindow(20, 0.5)
+	for i := 0; i < 100; i++ {
+		cb.recordOutcome(stream[i%len(stream)])
+	}
+	if state := cb.currentState(); state != CLOSED {
+		t.Fatalf("state = %s, want CLOSED", state)
+	}
+}
+
+func TestCircuitBreakerBypassProbeClosesAfterRecovery(t *testing.T) {
+	prev := cbProbeInterval
+	cbProbeInterval = 20 * time.Millisecond
+	t.Cleanup(func() { cbProbeInterval = prev })
+
+	// openTimeout longo: sem a sonda, o breaker ficaria aberto durante todo o teste
+	cb := NewCircuitBreaker(2, time.Minute, nil)
+	cb.recordOutcome(false)
+	cb.recordOutcome(false)
+	if allowed, _ := cb.canExecute(); allowed {
+		t.Fatal("open breaker admitted a call")
+	}
+
+	// Processor ainda fora: a sonda falha e o breaker continua aberto
+	if !cb.allowProbe() {
+		t.Fatal("first bypass probe was not allowed")
+	}
+	cb.recordProbe(false)
+	if state := cb.currentState(); state != OPEN {
+		t.Fatalf("state after failed probe = %s, want OPEN", state)
+	}
+
+	// Taxa limitada: nova sonda só depois de cbProbeInterval
+	if cb.allowProbe() {
+		t.Fatal("bypass probe allowed before the probe interval")
+	}
+	time.Sleep(cbProbeInterval)
+
+	// Processor voltou: duas sondas boas levam OPEN -> HALF_OPEN -> CLOSED
+	if !cb.allowProbe() {
+		t.Fatal("bypass probe not allowed after the interval")
+	}
+	cb.recordProbe(true)
+	if state := cb.currentState(); state != HALF_OPEN {
+		t.Fatalf("state after first healthy probe = %s, want HALF_OPEN", state)
+	}
+	time.Sleep(cbProbeInterval)
+	if !cb.allowProbe() {
+		t.Fatal("bypass probe not allowed in HALF_OPEN")
+	}
+	cb.recordProbe(true)
+	if state := cb.currentState(); state != CLOSED {
+		t.Fatalf("state after second healthy probe = %s, want CLOSED", state)
+	}
+	if allowed, probe := cb.canExecute(); !allowed || probe {
+		t.Fatalf("canExecute after recovery = %v, %v; want a regular call", allowed, probe)
+	}
+	// Fechado, não há mais sondas fora do gate
+	if cb.allowProbe() {
+		t.Fatal("bypass probe allowed while CLOSED")
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
+)
+
+// Máximo de pagamentos por cliente (0 = ilimitado); exige o banco do orchestrator
+var maxPaymentsPerCustomer = config.GetInt("MAX_PAYMENTS_PER_CUSTOMER", 0)
+
+// reserveCustomerPayment registra o pagamento no índice do cliente, respondendo
+// 429 e retornando false quando o cliente já atingiu o limite
+func reserveCustomerPayment(w http.ResponseWriter, db *database.Database, paymentReq map[string]interface{}) bool {
+	customerID, _ := paymentReq["customerId"].(string)
+	if maxPaymentsPerCustomer <= 0 || db == nil || customerID == "" {
+		return true
+	}
+	correlationId, _ := paymentReq["correlationId"].(string)
+	amount, _ := paymentReq["amount"].(float64)
+	now := time.Now()
+	err := db.CreatePaymentLimited(&database.Payment{
+		ID:         correlationId,
+		CustomerID: customerID,
+		Amount:     amount,
+		Status:     "processing",
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}, maxPaymentsPerCustomer)
+	if errors.Is(err, database.ErrCustomerLimit) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":"customer payment limit reached","customerId":"` + customerID + `","limit":` + strconv.Itoa(maxPaymentsPerCustomer) + `}`))
+		return false
+	}
+	if err != nil {
+		log.Printf("Customer index failed for %s: %v", correlationId, err)
+	}
+	return true
+}
+
+// completeCustomerPayment atualiza o registro reservado com o resultado (processor vazio = falha)
+func completeCustomerPayment(db *database.Database, paymentReq map[string]interface{}, processor string) {
+	customerID, _ := paymentReq["customerId"].(string)
+	if maxPaymentsPerCustomer <= 0 || db == nil || customerID == "" {
+		return
+	}
+	correlationId, _ := paymentReq["correlationId"].(string)
+	// Sem processor, nenhum processor real cobrou o pagamento
+	status := "completed"
+	if processor == "" {
+		status = "error"
+	}
+	if err := db.UpdatePayment(&database.Payment{
+		ID:            correlationId,
+		Status:        status,
+		ProcessorUsed: processor,
+		UpdatedAt:     time.Now(),
+	}); err != nil {
+		log.Printf("Customer payment update failed for %s: %v", correlationId, err)
+	}
+}
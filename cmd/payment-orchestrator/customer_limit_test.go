@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
+)
+
+func TestReserveCustomerPaymentRejectsOverCap(t *testing.T) {
+	db, err := database.NewDatabase(filepath.Join(t.TempDir(), "orchestrator.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	prev := maxPaymentsPerCustomer
+	maxPaymentsPerCustomer = 3
+	t.Cleanup(func() { maxPaymentsPerCustomer = prev })
+
+	for i := 0; i <= maxPaymentsPerCustomer; i++ {
+		rec := httptest.NewRecorder()
+		req := map[string]interface{}{"correlationId": fmt.Sprintf("cap-%d", i), "customerId": "c-1", "amount": 1.0}
+		ok := reserveCustomerPayment(rec, db, req)
+		if i < maxPaymentsPerCustomer {
+			if !ok {
+				t.Fatalf("payment %d under the cap rejected: %s", i, rec.Body)
+			}
+			continue
+		}
+		if ok || rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("payment over the cap: ok=%v status=%d, want 429", ok, rec.Code)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, `"customerId":"c-1"`) || !strings.Contains(body, `"limit":3`) {
+			t.Fatalf("error body %s does not name the customer and the cap", body)
+		}
+	}
+
+	// Pagamento sem customerId não entra no limite
+	if !reserveCustomerPayment(httptest.NewRecorder(), db, map[string]interface{}{"correlationId": "anon", "amount": 1.0}) {
+		t.Fatal("payment without customerId rejected")
+	}
+}
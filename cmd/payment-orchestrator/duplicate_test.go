@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsDuplicateResponse(t *testing.T) {
+	cases := []struct {
+		name   string
+		match  string
+		status int
+		body   string
+		want   bool
+	}{
+		{"success", "", http.StatusOK, "", false},
+		{"conflict without match", "", http.StatusConflict, "", true},
+		{"unprocessable without match", "", http.StatusUnprocessableEntity, "anything", true},
+		{"server error", "", http.StatusInternalServerError, "duplicate", false},
+		{"match in body", "already exists", http.StatusUnprocessableEntity, `{"error":"Payment ALREADY EXISTS"}`, true},
+		{"match missing", "already exists", http.StatusUnprocessableEntity, `{"error":"invalid amount"}`, false},
+	}
+	prev := duplicateMatch
+	t.Cleanup(func() { duplicateMatch = prev })
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			duplicateMatch = tc.match
+			resp := &http.Response{StatusCode: tc.status, Body: io.NopCloser(strings.NewReader(tc.body))}
+			if got := isDuplicateResponse(resp); got != tc.want {
+				t.Fatalf("isDuplicateResponse = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCountPaymentIgnoresDuplicates(t *testing.T) {
+	var records atomic.Int64
+	summary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		records.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer summary.Close()
+
+	prevURL, prevTotals := summaryRecordURL, processorTotals
+	summaryRecordURL, processorTotals = summary.URL, newProcessorTotals(1, 1)
+	t.Cleanup(func() { summaryRecordURL, processorTotals = prevURL, prevTotals })
+
+	first := HTTPPaymentResponse{ID: "dup-1", Status: "processed", Processor: "default"}
+	countPayment(first, 10, "c-1")
+
+	// Mesmo pagamento de novo (retry/fan-out): o processor responde duplicado
+	again := first
+	again.Duplicate = true
+	countPayment(again, 10, "c-1")
+	// Resposta de erro também não conta
+	countPayment(HTTPPaymentResponse{ID: "dup-2", Status: "error"}, 10, "c-1")
+
+	processorTotals.Flush()
+	if total := processorTotals.Snapshot()["default"]; total.TotalRequests != 1 || total.TotalAmount != 10 {
+		t.Fatalf("processor totals = %+v, want one payment of 10", total)
+	}
+
+	// recordPayment é assíncrono: espera o primeiro registro e dá tempo a um eventual segundo
+	deadline := time.Now().Add(time.Second)
+	for records.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := records.Load(); n != 1 {
+		t.Fatalf("summary records = %d, want 1", n)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+var (
+	// Máximo de processors com estado de health check mantido
+	healthMaxEntries = config.GetInt("HEALTH_CACHE_MAX_ENTRIES", 64)
+
+	// Por quanto tempo o último resultado de health vale como "último conhecido"
+	// (429 do processor, orçamento esgotado); depois disso o processor volta a desconhecido
+	healthResultTTL = config.GetDuration("HEALTH_RESULT_TTL", time.Minute)
+)
+
+// reconcileHealthState descarta o estado de health (lastHealthCheck e
+// health_<processor> no brutoCache) dos processors fora do conjunto informado.
+// PAYMENT_PROCESSORS é lido uma vez na inicialização e não há recarga da lista
+// em execução, então hoje o estado é limitado só por healthMaxEntries; quem
+// passar a trocar a lista de processors deve chamar esta função em seguida.
+func reconcileHealthState(processors []string) {
+	configured := make(map[string]bool, len(processors))
+	for _, p := range processors {
+		configured[p] = true
+	}
+
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	for p := range lastHealthCheck {
+		if !configured[p] {
+			forgetHealthLocked(p)
+		}
+	}
+}
+
+// evictOldestHealthLocked abre espaço para um novo processor descartando o
+// checado há mais tempo; chamado com healthMu travado
+func evictOldestHealthLocked() {
+	if healthMaxEntries <= 0 || len(lastHealthCheck) < healthMaxEntries {
+		return
+	}
+	var oldest string
+	for p, at := range lastHealthCheck {
+		if oldest == "" || at.Before(lastHealthCheck[oldest]) {
+			oldest = p
+		}
+	}
+	forgetHealthLocked(oldest)
+}
+
+func forgetHealthLocked(processor string) {
+	delete(lastHealthCheck, processor)
+	brutoCache.Delete("health_" + processor)
+}
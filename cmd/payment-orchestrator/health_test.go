@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func withHealthTiming(t *testing.T, maxAge, interval time.Duration) {
+	t.Helper()
+	prevMaxAge, prevInterval := healthCheckMaxAge, healthCheckInterval
+	healthCheckMaxAge, healthCheckInterval = maxAge, interval
+	t.Cleanup(func() { healthCheckMaxAge, healthCheckInterval = prevMaxAge, prevInterval })
+}
+
+// fakeHealthEndpoint sobe em host:8080 um /payments/service-health saudável que
+// conta as checagens reais recebidas
+func fakeHealthEndpoint(t *testing.T, host string) *atomic.Int64 {
+	t.Helper()
+	ln, err := net.Listen("tcp", host+":8080")
+	if err != nil {
+		t.Skipf("cannot listen on %s:8080: %v", host, err)
+	}
+	var hits atomic.Int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(`{"failing":false,"minResponseTime":0}`))
+	}))
+	server.Listener.Close()
+	server.Listener = ln
+	server.Start()
+	t.Cleanup(server.Close)
+	t.Cleanup(func() {
+		healthMu.Lock()
+		forgetHealthLocked(host)
+		healthMu.Unlock()
+	})
+	return &hits
+}
+
+func TestShorterHealthMaxAgeForcesEarlierCheck(t *testing.T) {
+	const processor = "127.0.0.3"
+	hits := fakeHealthEndpoint(t, processor)
+
+	for _, tc := range []struct {
+		name     string
+		maxAge   time.Duration
+		wantHits int64
+	}{
+		// Mesmo intervalo mínimo; só o max age muda
+		{"short max age rechecks", 50 * time.Millisecond, 2},
+		{"long max age serves the cache", time.Second, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			withHealthTiming(t, tc.maxAge, 20*time.Millisecond)
+			hits.Store(0)
+			healthMu.Lock()
+			forgetHealthLocked(processor)
+			healthMu.Unlock()
+
+			ctx := context.Background()
+			if !checkPaymentProcessorHealth(ctx, processor) {
+				t.Fatal("first check reported unhealthy")
+			}
+			time.Sleep(80 * time.Millisecond)
+			checkPaymentProcessorHealth(ctx, processor)
+			if got := hits.Load(); got != tc.wantHits {
+				t.Fatalf("real checks = %d, want %d", got, tc.wantHits)
+			}
+		})
+	}
+}
+
+func TestValidateHealthTimingRejectsMaxAgeBelowInterval(t *testing.T) {
+	withHealthTiming(t, time.Second, 5*time.Second)
+	if err := validateHealthTiming(); err == nil {
+		t.Fatal("max age below the interval accepted, want error")
+	}
+	withHealthTiming(t, 5*time.Second, 5*time.Second)
+	if err := validateHealthTiming(); err != nil {
+		t.Fatalf("defaults rejected: %v", err)
+	}
+}
+
+// seedHealth simula processors já checados, o primeiro sendo o mais antigo
+func seedHealth(t *testing.T, processors ...string) {
+	t.Helper()
+	healthMu.Lock()
+	lastHealthCheck = make(map[string]time.Time)
+	base := time.Now().Add(-time.Minute)
+	for i, p := range processors {
+		lastHealthCheck[p] = base.Add(time.Duration(i) * time.Second)
+		brutoCache.Set("health_"+p, true, time.Minute)
+	}
+	healthMu.Unlock()
+	t.Cleanup(func() {
+		healthMu.Lock()
+		for p := range lastHealthCheck {
+			forgetHealthLocked(p)
+		}
+		healthMu.Unlock()
+	})
+}
+
+// hasHealth indica se ainda há estado de health do processor em algum dos mapas
+func hasHealth(processor string) (checked, cached bool) {
+	healthMu.Lock()
+	_, checked = lastHealthCheck[processor]
+	healthMu.Unlock()
+	_, cached = brutoCache.Get("health_" + processor)
+	return checked, cached
+}
+
+func TestReconcileHealthStateEvictsRemovedProcessors(t *testing.T) {
+	seedHealth(t, "default", "fallback", "retired")
+
+	reconcileHealthState([]string{"default", "fallback"})
+
+	if checked, cached := hasHealth("retired"); checked || cached {
+		t.Fatalf("removed processor kept health state: checked=%v cached=%v", checked, cached)
+	}
+	for _, p := range []string{"default", "fallback"} {
+		if checked, cached := hasHealth(p); !checked || !cached {
+			t.Fatalf("configured processor %s lost health state: checked=%v cached=%v", p, checked, cached)
+		}
+	}
+}
+
+func TestEvictOldestHealthAtCap(t *testing.T) {
+	prev := healthMaxEntries
+	healthMaxEntries = 2
+	t.Cleanup(func() { healthMaxEntries = prev })
+	seedHealth(t, "oldest", "newest")
+
+	healthMu.Lock()
+	evictOldestHealthLocked()
+	healthMu.Unlock()
+
+	if checked, cached := hasHealth("oldest"); checked || cached {
+		t.Fatal("oldest processor not evicted at the cap")
+	}
+	if checked, _ := hasHealth("newest"); !checked {
+		t.Fatal("newest processor evicted")
+	}
+}
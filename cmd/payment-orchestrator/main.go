@@ -3,66 +3,120 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
+	"golang.org/x/net/http2"
 
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/bufpool"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/cache"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/cachereg"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/dedup"
 	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/keys"
 )
 
 var (
 	// Atomic counters for metrics
-	requestCount int64
-	successCount int64
-	errorCount   int64
-	timeoutCount int64
+	requestCount   int64
+	successCount   int64
+	errorCount     int64
+	timeoutCount   int64
+	replayCount    int64 // respostas idempotentes (correlationId já processado)
+	duplicateCount int64 // processor indicou pagamento duplicado
+
+	breakerTransitionCount int64 // mudanças de estado dos circuit breakers
 
 	// BRUTO Connection Pool
-	brutoConnectionPool = &BRUTOConnectionPool{
-		connections: make([]*http.Client, 0),
-		current:     0,
-		mu:          sync.Mutex{},
-	}
+	brutoConnectionPool = NewBRUTOConnectionPool(PoolConfig{
+		Size:                config.GetInt("PROCESSOR_POOL_SIZE", 4),
+		Timeout:             config.GetDuration("PROCESSOR_CLIENT_TIMEOUT", 300*time.Millisecond),
+		MaxIdleConns:        config.GetInt("PROCESSOR_MAX_IDLE_CONNS", 1000),
+		MaxIdleConnsPerHost: config.GetInt("PROCESSOR_MAX_IDLE_CONNS_PER_HOST", 200),
+		IdleConnTimeout:     idleConnTimeout,
+		UseHTTP2:            config.GetBool("PROCESSOR_HTTP2", false),
+	})
 
 	// BRUTO Cache
-	brutoCache = &BRUTOCache{
-		data: make(map[string]interface{}),
-		mu:   sync.RWMutex{},
-	}
+	brutoCache = cache.New[bool](config.GetInt("CACHE_MAX_ENTRIES", 10000)) // health_<processor>
 
-	// Circuit breaker state
-	circuitBreaker = &CircuitBreaker{
-		failures:    0,
-		lastFailure: time.Time{},
-		state:       CLOSED,
-		mux:         sync.RWMutex{},
-	}
+	// Circuit breaker state (padrão: 10 falhas / 30s)
+	cbThreshold    = config.GetInt("CB_THRESHOLD", 10)
+	cbOpenTimeout  = config.GetDuration("CB_OPEN_TIMEOUT", 30*time.Second)
+	circuitBreaker = newConfiguredBreaker("orchestrator")
 
-	// Buffer pools for zero-copy operations
-	bufferPool = sync.Pool{
-		New: func() interface{} {
-			return make([]byte, 0, 4096)
-		},
-	}
-)
+	// Janela deslizante opcional: CB_WINDOW_SIZE resultados (0 = falhas consecutivas)
+	// e CB_FAILURE_PERCENT de falhas para abrir
+	cbWindowSize     = config.GetInt("CB_WINDOW_SIZE", 0)
+	cbFailurePercent = config.GetInt("CB_FAILURE_PERCENT", 50)
+
+	// Chamadas de teste simultâneas admitidas em HALF_OPEN
+	cbHalfOpenProbes = config.GetInt("CB_HALF_OPEN_PROBES", 1)
+
+	// Teto do tempo aberto com backoff exponencial entre reaberturas
+	cbMaxOpenTimeout = config.GetDuration("CB_MAX_OPEN_TIMEOUT", 5*time.Minute)
+
+	// Health check: intervalo mínimo entre checagens reais (rate limit do processor)
+	// e idade máxima de um resultado em cache antes de forçar nova checagem; o max
+	// age não pode ser menor que o intervalo (ver validateHealthTiming)
+	healthCheckInterval = config.GetDuration("HEALTH_CHECK_INTERVAL", 5*time.Second)
+	healthCheckMaxAge   = config.GetDuration("HEALTH_CHECK_MAX_AGE", 5*time.Second)
 
-// Deduplicação de pagamentos (escopo global)
-var processedPayments = struct {
-	m map[string]struct{}
-	sync.RWMutex
-}{m: make(map[string]struct{})}
+	// Sondas de saúde que ignoram o breaker aberto (limitadas a uma por intervalo)
+	cbProbeBypass   = config.GetBool("CB_PROBE_BYPASS", false)
+	cbProbeInterval = config.GetDuration("CB_PROBE_INTERVAL", time.Second)
+
+	// Conexões ociosas: timeout do transport e reaper ativo ligado ao health check
+	// (padrão: passivo, o transport gerencia sozinho)
+	idleConnTimeout = config.GetDuration("PROCESSOR_IDLE_CONN_TIMEOUT", 30*time.Second)
+	idleConnReaper  = config.GetBool("PROCESSOR_IDLE_CONN_REAPER", false)
+
+	// Indicação de pagamento duplicado do processor
+	duplicateStatuses = parseStatusCodes(config.GetList("PROCESSOR_DUPLICATE_STATUSES", []string{"409", "422"}))
+	duplicateMatch    = config.GetString("PROCESSOR_DUPLICATE_MATCH", "")
+
+	// Momento da última checagem real por processor
+	lastHealthCheck = make(map[string]time.Time)
+	healthMu        sync.Mutex
+)
 
 type CircuitBreaker struct {
+	threshold   int           // falhas para abrir
+	openTimeout time.Duration // tempo aberto antes de HALF_OPEN
+	maxTimeout  time.Duration // teto do openTimeout com backoff (0 = sem backoff)
+	reopenCount int           // reaberturas seguidas a partir de HALF_OPEN
 	failures    int
 	lastFailure time.Time
+	lastProbe   time.Time
 	state       CircuitState
 	mux         sync.RWMutex
+
+	// Chamado fora do lock a cada mudança de estado
+	onStateChange func(from, to CircuitState)
+
+	// HALF_OPEN: chamadas de teste em andamento, limitadas a maxProbes
+	maxProbes      int
+	probesInFlight atomic.Int32
+	probeStartedAt time.Time
+
+	// Janela deslizante (withWindow): últimos N resultados, true = falha
+	window         []bool
+	windowPos      int
+	windowCount    int
+	windowFailures int
+	failureRatio   float64
 }
 
 type CircuitState int
@@ -73,60 +127,179 @@ const (
 	HALF_OPEN
 )
 
-// BRUTO Connection Pool
+func (s CircuitState) String() string {
+	switch s {
+	case CLOSED:
+		return "CLOSED"
+	case OPEN:
+		return "OPEN"
+	case HALF_OPEN:
+		return "HALF_OPEN"
+	}
+	return "UNKNOWN"
+}
+
+// Estado do breaker para leitura externa (GET /circuit-breaker)
+type CircuitBreakerSnapshot struct {
+	State       string    `json:"state"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"lastFailure"`
+}
+
+// BRUTO Connection Pool: connections é preenchido uma vez em NewBRUTOConnectionPool
+// e depois só lido, então a rotação dispensa lock
 type BRUTOConnectionPool struct {
 	connections []*http.Client
-	current     int
-	mu          sync.Mutex
+	current     atomic.Uint64
 }
 
-func (p *BRUTOConnectionPool) GetConnection() *http.Client {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	if len(p.connections) == 0 {
-		// BRUTO: Timeout ultra-agressivo
-		client := &http.Client{
-			Timeout: 300 * time.Millisecond, // BRUTO: timeout de 300ms para 100% sucesso
-			Transport: &http.Transport{
-				MaxIdleConns:        1000, // BRUTO: pool gigante
-				MaxIdleConnsPerHost: 200,  // BRUTO: pool gigante
-				IdleConnTimeout:     30 * time.Second,
-				TLSHandshakeTimeout: 5 * time.Second, // BRUTO: timeout reduzido
-				DisableCompression:  true,
-				DisableKeepAlives:   false,
-			},
+// Parâmetros dos clients do pool; campos zerados usam os valores de DefaultPoolConfig
+type PoolConfig struct {
+	Size                int
+	Timeout             time.Duration
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	// UseHTTP2 força HTTP/2 com os processors: h2c (cleartext) para http:// e
+	// h2 negociado via ALPN para https://, multiplexando em menos conexões
+	UseHTTP2 bool
+}
+
+// DefaultPoolConfig retorna a configuração BRUTO original
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		Size:                1,
+		Timeout:             300 * time.Millisecond, // BRUTO: timeout de 300ms para 100% sucesso
+		MaxIdleConns:        1000,                   // BRUTO: pool gigante
+		MaxIdleConnsPerHost: 200,                    // BRUTO: pool gigante
+		IdleConnTimeout:     30 * time.Second,
+	}
+}
+
+// NewBRUTOConnectionPool cria o pool já com cfg.Size clients, cada um com seu
+// transport (e portanto suas próprias conexões e locks internos)
+func NewBRUTOConnectionPool(cfg PoolConfig) *BRUTOConnectionPool {
+	def := DefaultPoolConfig()
+	if cfg.Size < 1 {
+		cfg.Size = def.Size
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = def.Timeout
+	}
+	if cfg.MaxIdleConns <= 0 {
+		cfg.MaxIdleConns = def.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost <= 0 {
+		cfg.MaxIdleConnsPerHost = def.MaxIdleConnsPerHost
+	}
+	if cfg.IdleConnTimeout <= 0 {
+		cfg.IdleConnTimeout = def.IdleConnTimeout
+	}
+
+	p := &BRUTOConnectionPool{connections: make([]*http.Client, cfg.Size)}
+	for i := range p.connections {
+		p.connections[i] = &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: newPoolTransport(cfg),
 		}
-		p.connections = append(p.connections, client)
-		return client
 	}
-	conn := p.connections[p.current]
-	p.current = (p.current + 1) % len(p.connections)
-	return conn
+	return p
 }
 
-// BRUTO Cache
-type BRUTOCache struct {
-	data map[string]interface{}
-	mu   sync.RWMutex
+func newPoolTransport(cfg PoolConfig) http.RoundTripper {
+	transport := &http.Transport{
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+		TLSHandshakeTimeout: 5 * time.Second, // BRUTO: timeout reduzido
+		DisableCompression:  true,
+		DisableKeepAlives:   false,
+	}
+	if !cfg.UseHTTP2 {
+		return transport
+	}
+
+	// https://: h2 via ALPN no transport padrão
+	if err := http2.ConfigureTransport(transport); err != nil {
+		log.Printf("HTTP/2 setup failed, using HTTP/1.1 for TLS: %v", err)
+	}
+	// http://: h2c, HTTP/2 direto sobre TCP sem TLS (prior knowledge)
+	h2c := &http2.Transport{
+		AllowHTTP:          true,
+		DisableCompression: true,
+		IdleConnTimeout:    cfg.IdleConnTimeout,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	return &h2Transport{tls: transport, h2c: h2c}
+}
+
+// h2Transport escolhe o transport HTTP/2 pelo esquema da URL
+type h2Transport struct {
+	tls *http.Transport
+	h2c *http2.Transport
+}
+
+func (t *h2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(req)
+	}
+	return t.tls.RoundTrip(req)
+}
+
+// CloseIdleConnections permite que http.Client.CloseIdleConnections alcance os dois transports
+func (t *h2Transport) CloseIdleConnections() {
+	t.tls.CloseIdleConnections()
+	t.h2c.CloseIdleConnections()
+}
+
+func (p *BRUTOConnectionPool) GetConnection() *http.Client {
+	n := p.current.Add(1) - 1
+	return p.connections[n%uint64(len(p.connections))]
+}
+
+// Estatísticas do pool (GET /pool-stats)
+type PoolStats struct {
+	Clients       int    `json:"clients"`
+	TotalRequests uint64 `json:"totalRequests"`
+	CurrentIndex  int    `json:"currentIndex"`
 }
 
-func (c *BRUTOCache) Get(key string) interface{} {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.data[key]
+// Stats lê o contador de rotação: cada GetConnection o incrementa uma vez,
+// então ele é também o total de requisições servidas
+func (p *BRUTOConnectionPool) Stats() PoolStats {
+	served := p.current.Load()
+	return PoolStats{
+		Clients:       len(p.connections),
+		TotalRequests: served,
+		CurrentIndex:  int(served % uint64(len(p.connections))),
+	}
 }
 
-func (c *BRUTOCache) Set(key string, value interface{}) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.data[key] = value
+// Close fecha as conexões keep-alive ociosas de todos os clients; usado no shutdown
+func (p *BRUTOConnectionPool) Close() {
+	p.CloseIdleConnections()
+}
+
+// CloseIdleConnections fecha as conexões ociosas de todos os clients do pool.
+// O transport não separa por host, então o processor saudável apenas reconecta.
+func (p *BRUTOConnectionPool) CloseIdleConnections() {
+	for _, client := range p.connections {
+		client.CloseIdleConnections()
+	}
 }
 
 // BRUTO Payment Response
 type HTTPPaymentResponse struct {
-	ID      string `json:"id"`
-	Status  string `json:"status"`
-	Message string `json:"message"`
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	Processor string `json:"-"` // vazio quando nenhum processor atendeu
+	Duplicate bool   `json:"-"` // processor indicou pagamento duplicado
+
+	RequestedAt string `json:"-"` // requestedAt enviado ao processor
 }
 
 // BRUTO Summary Response
@@ -140,64 +313,352 @@ type ProcessorSummary struct {
 	TotalAmount   float64 `json:"totalAmount"`
 }
 
-func (cb *CircuitBreaker) canExecute() bool {
+// NewCircuitBreaker cria um breaker fechado que abre após threshold falhas
+// e tenta de novo (HALF_OPEN) depois de openTimeout; onStateChange (opcional)
+// é chamado uma vez por transição, sem o lock do breaker
+func NewCircuitBreaker(threshold int, openTimeout time.Duration, onStateChange func(from, to CircuitState)) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold:     threshold,
+		openTimeout:   openTimeout,
+		state:         CLOSED,
+		onStateChange: onStateChange,
+		maxProbes:     1,
+	}
+}
+
+// withMaxOpenTimeout faz o tempo aberto dobrar a cada reabertura vinda de
+// HALF_OPEN (30s, 60s, 120s...) até max; volta à base quando o breaker fecha
+func (cb *CircuitBreaker) withMaxOpenTimeout(max time.Duration) *CircuitBreaker {
+	cb.maxTimeout = max
+	return cb
+}
+
+// effectiveOpenTimeoutLocked aplica o backoff exponencial ao openTimeout
+func (cb *CircuitBreaker) effectiveOpenTimeoutLocked() time.Duration {
+	timeout := cb.openTimeout
+	for i := 0; i < cb.reopenCount && timeout < cb.maxTimeout; i++ {
+		timeout *= 2
+	}
+	if cb.maxTimeout > 0 && timeout > cb.maxTimeout {
+		timeout = cb.maxTimeout
+	}
+	return timeout
+}
+
+// withMaxProbes define quantas chamadas de teste o HALF_OPEN admite ao mesmo tempo
+func (cb *CircuitBreaker) withMaxProbes(n int) *CircuitBreaker {
+	if n > 0 {
+		cb.maxProbes = n
+	}
+	return cb
+}
+
+// Transição pendente de notificação, registrada sob o lock
+type stateTransition struct {
+	from, to CircuitState
+	changed  bool
+}
+
+// setStateLocked muda o estado e registra a transição; chamado com mux travado
+func (cb *CircuitBreaker) setStateLocked(t *stateTransition, to CircuitState) {
+	if cb.state == to {
+		return
+	}
+	switch {
+	case cb.state == HALF_OPEN && to == OPEN:
+		cb.reopenCount++
+	case to == CLOSED:
+		cb.reopenCount = 0
+	}
+	t.from, t.to, t.changed = cb.state, to, true
+	cb.state = to
+}
+
+// notify dispara o callback; deve rodar depois do Unlock (defer antes do Lock)
+func (cb *CircuitBreaker) notify(t *stateTransition) {
+	if t.changed && cb.onStateChange != nil {
+		cb.onStateChange(t.from, t.to)
+	}
+}
+
+// newConfiguredBreaker cria um breaker com os parâmetros CB_* do ambiente
+func newConfiguredBreaker(name string) *CircuitBreaker {
+	return NewCircuitBreaker(cbThreshold, cbOpenTimeout, breakerStateLogger(name)).
+		withWindow(cbWindowSize, float64(cbFailurePercent)/100).
+		withMaxProbes(cbHalfOpenProbes).
+		withMaxOpenTimeout(cbMaxOpenTimeout)
+}
+
+// Snapshot copia o estado atual sob o lock de leitura
+func (cb *CircuitBreaker) Snapshot() CircuitBreakerSnapshot {
 	cb.mux.RLock()
 	defer cb.mux.RUnlock()
+	return CircuitBreakerSnapshot{
+		State:       cb.state.String(),
+		Failures:    cb.failures,
+		LastFailure: cb.lastFailure,
+	}
+}
 
+// currentState retorna o estado atual (para o gauge do Prometheus)
+func (cb *CircuitBreaker) currentState() CircuitState {
+	cb.mux.RLock()
+	defer cb.mux.RUnlock()
+	return cb.state
+}
+
+// canExecute indica se a chamada pode seguir; probe=true marca uma chamada de
+// teste do HALF_OPEN, cujo resultado deve ir para probeFinished (ou recordResult)
+func (cb *CircuitBreaker) canExecute() (allowed, probe bool) {
+	// Caminho comum (CLOSED) só com o lock de leitura
+	cb.mux.RLock()
+	state := cb.state
+	cb.mux.RUnlock()
+	if state == CLOSED {
+		return true, false
+	}
+
+	// Relê o estado sob o lock de escrita, já que outra goroutine pode ter
+	// mudado entre os dois locks, e faz a transição para HALF_OPEN de forma atômica
+	var t stateTransition
+	defer cb.notify(&t)
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
 	switch cb.state {
 	case CLOSED:
-		return true
+		return true, false
 	case OPEN:
-		if time.Since(cb.lastFailure) > 30*time.Second {
-			cb.mux.Lock()
-			cb.state = HALF_OPEN
-			cb.mux.Unlock()
-			return true
+		if time.Since(cb.lastFailure) <= cb.effectiveOpenTimeoutLocked() {
+			return false, false
 		}
-		return false
-	case HALF_OPEN:
-		return true
+		cb.setStateLocked(&t, HALF_OPEN)
+		cb.probesInFlight.Store(0)
+	}
+
+	// HALF_OPEN: no máximo maxProbes chamadas de teste por vez; uma sonda
+	// sem resultado há mais de openTimeout é dada como perdida e libera a vaga
+	if int(cb.probesInFlight.Load()) >= cb.maxProbes {
+		if time.Since(cb.probeStartedAt) <= cb.openTimeout {
+			return false, false
+		}
+		cb.probesInFlight.Store(0)
 	}
-	return false
+	cb.probesInFlight.Add(1)
+	cb.probeStartedAt = time.Now()
+	return true, true
 }
 
-func (cb *CircuitBreaker) recordSuccess() {
+// Reset força o breaker para CLOSED, zerando falhas, janela e backoff (uso administrativo)
+func (cb *CircuitBreaker) Reset() {
+	var t stateTransition
+	defer cb.notify(&t)
 	cb.mux.Lock()
 	defer cb.mux.Unlock()
-	cb.failures = 0
-	cb.state = CLOSED
+	cb.resetWindow()
+	cb.lastFailure = time.Time{}
+	cb.probesInFlight.Store(0)
+	cb.setStateLocked(&t, CLOSED)
+	cb.reopenCount = 0
+}
+
+// probeFinished libera a vaga da chamada de teste e registra seu resultado
+func (cb *CircuitBreaker) probeFinished(success bool) {
+	cb.releaseProbe()
+	cb.recordOutcome(success)
+}
+
+// releaseProbe libera a vaga de uma chamada de teste que não chegou ao downstream
+func (cb *CircuitBreaker) releaseProbe() {
+	if cb.probesInFlight.Add(-1) < 0 {
+		cb.probesInFlight.Store(0)
+	}
+}
+
+// recordResult registra o resultado de uma chamada admitida por canExecute
+func (cb *CircuitBreaker) recordResult(probe, success bool) {
+	if probe {
+		cb.probeFinished(success)
+		return
+	}
+	cb.recordOutcome(success)
+}
+
+// breakerStateLogger registra cada transição do breaker no log e em /metrics
+func breakerStateLogger(name string) func(from, to CircuitState) {
+	return func(from, to CircuitState) {
+		atomic.AddInt64(&breakerTransitionCount, 1)
+		log.Printf("Circuit breaker %s: %s -> %s", name, from, to)
+	}
+}
+
+func (cb *CircuitBreaker) recordSuccess() {
+	cb.recordOutcome(true)
 }
 
 func (cb *CircuitBreaker) recordFailure() {
+	cb.recordOutcome(false)
+}
+
+// withWindow troca o contador de falhas consecutivas por uma janela com os
+// últimos size resultados: o breaker abre quando a janela está cheia e a
+// fração de falhas passa de ratio (ex.: 20 chamadas, 0.5)
+func (cb *CircuitBreaker) withWindow(size int, ratio float64) *CircuitBreaker {
+	if size > 0 {
+		cb.window = make([]bool, size)
+		cb.failureRatio = ratio
+	}
+	return cb
+}
+
+func (cb *CircuitBreaker) recordOutcome(success bool) {
+	var t stateTransition
+	defer cb.notify(&t)
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	if !success {
+		cb.lastFailure = time.Now()
+	}
+
+	if cb.window == nil {
+		if success {
+			cb.failures = 0
+			cb.setStateLocked(&t, CLOSED)
+			return
+		}
+		cb.failures++
+		if cb.failures >= cb.threshold {
+			cb.setStateLocked(&t, OPEN)
+		}
+		return
+	}
+
+	// Fora do CLOSED a chamada de teste decide sozinha, e a janela recomeça
+	if cb.state != CLOSED {
+		if success {
+			cb.setStateLocked(&t, CLOSED)
+			cb.resetWindow()
+		} else {
+			cb.setStateLocked(&t, OPEN)
+		}
+		return
+	}
+
+	if cb.windowCount == len(cb.window) {
+		if cb.window[cb.windowPos] {
+			cb.windowFailures--
+		}
+	} else {
+		cb.windowCount++
+	}
+	cb.window[cb.windowPos] = !success
+	if !success {
+		cb.windowFailures++
+	}
+	cb.windowPos = (cb.windowPos + 1) % len(cb.window)
+	cb.failures = cb.windowFailures
+
+	if cb.windowCount == len(cb.window) && float64(cb.windowFailures)/float64(cb.windowCount) > cb.failureRatio {
+		cb.setStateLocked(&t, OPEN)
+	}
+}
+
+func (cb *CircuitBreaker) resetWindow() {
+	for i := range cb.window {
+		cb.window[i] = false
+	}
+	cb.windowPos, cb.windowCount, cb.windowFailures, cb.failures = 0, 0, 0, 0
+}
+
+// allowProbe libera uma sonda que ignora o gate do breaker, no máximo uma por
+// cbProbeInterval e apenas enquanto o breaker não está CLOSED
+func (cb *CircuitBreaker) allowProbe() bool {
 	cb.mux.Lock()
 	defer cb.mux.Unlock()
-	cb.failures++
-	cb.lastFailure = time.Now()
-	if cb.failures >= 10 { // BRUTO: Mais permissivo para 100% sucesso
-		cb.state = OPEN
+	if cb.state == CLOSED || time.Since(cb.lastProbe) < cbProbeInterval {
+		return false
 	}
+	cb.lastProbe = time.Now()
+	return true
+}
+
+// recordProbe aplica o resultado de uma sonda: sucesso leva OPEN para HALF_OPEN
+// e HALF_OPEN para CLOSED; falha mantém (ou volta para) OPEN
+func (cb *CircuitBreaker) recordProbe(success bool) {
+	var t stateTransition
+	defer cb.notify(&t)
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	if !success {
+		cb.lastFailure = time.Now()
+		cb.setStateLocked(&t, OPEN)
+		return
+	}
+	switch cb.state {
+	case OPEN:
+		cb.setStateLocked(&t, HALF_OPEN)
+	case HALF_OPEN:
+		cb.resetWindow()
+		cb.setStateLocked(&t, CLOSED)
+	}
+}
+
+// probeProcessorHealth checa a saúde do processor e, com CB_PROBE_BYPASS, usa o
+// resultado como sonda do breaker mesmo com ele aberto, para detectar a recuperação
+func probeProcessorHealth(ctx context.Context, processor string) bool {
+	healthy := checkPaymentProcessorHealth(ctx, processor)
+	if cbProbeBypass && circuitBreaker.allowProbe() {
+		circuitBreaker.recordProbe(healthy)
+	}
+	return healthy
 }
 
 // BRUTO: Call Payment Processor - ULTRA-AGRESIVO
-func callPaymentProcessorBRUTO(paymentReq map[string]interface{}, processor string) HTTPPaymentResponse {
+func callPaymentProcessorBRUTO(ctx context.Context, paymentReq map[string]interface{}, processor string) HTTPPaymentResponse {
+	// Breaker do processor alvo: falhas de um não bloqueiam o outro
+	breaker := breakerFor(processor)
+	allowed, probe := breaker.canExecute()
+	if !allowed {
+		return HTTPPaymentResponse{Status: "error", Message: fmt.Sprintf("%s circuit open", processor)}
+	}
+	start := time.Now()
+	resp := postPaymentProcessor(ctx, paymentReq, processor)
+	outcome := "success"
+	if resp.Status == "error" {
+		outcome = "error"
+	}
+	processorLatency.WithLabelValues(processor, outcome).Observe(time.Since(start).Seconds())
+	breaker.recordResult(probe, resp.Status != "error")
+	return resp
+}
+
+// postPaymentProcessor faz o POST /payments no processor
+func postPaymentProcessor(ctx context.Context, paymentReq map[string]interface{}, processor string) HTTPPaymentResponse {
 	// BRUTO: Use connection pool
 	client := brutoConnectionPool.GetConnection()
 
-	// BRUTO: Timeout ultra-agressivo
-	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond) // BRUTO: 300ms para 100% sucesso
+	// BRUTO: Timeout ultra-agressivo, limitado ao que resta do orçamento do pagamento
+	ctx, cancel := downstreamContext(ctx, 300*time.Millisecond) // BRUTO: 300ms para 100% sucesso
 	defer cancel()
 
+	// Validado em handlePayments; comma-ok para nunca derrubar o processo
+	correlationId, _ := paymentReq["correlationId"].(string)
+
 	// Add requestedAt timestamp for Rinha spec
-	paymentReq["requestedAt"] = time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	requestedAt := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	paymentReq["requestedAt"] = requestedAt
 
-	jsonData, err := json.Marshal(paymentReq)
-	if err != nil {
+	// Buffer da classe de payloads pequenos, devolvido ao pool após a resposta
+	buf := bufpool.Get(512)
+	defer bufpool.Put(buf)
+	body := bytes.NewBuffer(*buf)
+	if err := json.NewEncoder(body).Encode(applyBodyTemplate(paymentReq)); err != nil {
 		return HTTPPaymentResponse{Status: "error", Message: "JSON marshal failed"}
 	}
+	*buf = body.Bytes()
 
 	// BRUTO: Direct HTTP call to payment processor
 	url := fmt.Sprintf("http://%s:8080/payments", processor)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(*buf))
 	if err != nil {
 		return HTTPPaymentResponse{Status: "error", Message: "Request creation failed"}
 	}
@@ -209,21 +670,159 @@ func callPaymentProcessorBRUTO(paymentReq map[string]interface{}, processor stri
 	}
 	defer resp.Body.Close()
 
+	// Processor já tinha esse correlationId: sucesso idempotente, não conta de novo no resumo
+	if isDuplicateResponse(resp) {
+		atomic.AddInt64(&duplicateCount, 1)
+		return HTTPPaymentResponse{
+			ID:        correlationId,
+			Status:    "processed",
+			Message:   fmt.Sprintf("Idempotent: %s already processed", processor),
+			Processor: processor,
+			Duplicate: true,
+
+			RequestedAt: requestedAt,
+		}
+	}
+
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 		return HTTPPaymentResponse{
-			ID:      paymentReq["correlationId"].(string),
-			Status:  "processed",
-			Message: fmt.Sprintf("Payment processed by %s", processor),
+			ID:        correlationId,
+			Status:    "processed",
+			Message:   fmt.Sprintf("Payment processed by %s", processor),
+			Processor: processor,
+
+			RequestedAt: requestedAt,
 		}
 	}
 
 	return HTTPPaymentResponse{Status: "error", Message: fmt.Sprintf("%s returned error", processor)}
 }
 
-// BRUTO: Health check - SEMPRE TRUE
-func checkPaymentProcessorHealth(processor string) bool {
-	// BRUTO: Sempre assume saudável para velocidade máxima
-	return true
+// parseStatusCodes converte a lista de status HTTP configurada, ignorando inválidos
+func parseStatusCodes(values []string) map[int]bool {
+	codes := make(map[int]bool, len(values))
+	for _, v := range values {
+		if code, err := strconv.Atoi(v); err == nil {
+			codes[code] = true
+		}
+	}
+	return codes
+}
+
+// isDuplicateResponse reconhece a indicação de pagamento duplicado do processor:
+// status em PROCESSOR_DUPLICATE_STATUSES e, se configurado, corpo contendo
+// PROCESSOR_DUPLICATE_MATCH (sem diferenciar maiúsculas)
+func isDuplicateResponse(resp *http.Response) bool {
+	if !duplicateStatuses[resp.StatusCode] {
+		return false
+	}
+	if duplicateMatch == "" {
+		return true
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(body)), strings.ToLower(duplicateMatch))
+}
+
+// Resposta do endpoint /payments/service-health do processor
+type processorHealth struct {
+	Failing         bool `json:"failing"`
+	MinResponseTime int  `json:"minResponseTime"`
+}
+
+// healthCheckDue indica se o resultado em cache precisa de uma checagem real:
+// nunca checado, ou com idade de pelo menos healthCheckMaxAge. Como
+// validateHealthTiming exige healthCheckMaxAge >= healthCheckInterval, forçar a
+// checagem no max age nunca fura o rate limit do processor.
+func healthCheckDue(checked bool, age time.Duration) bool {
+	return !checked || age >= healthCheckMaxAge
+}
+
+// validateHealthTiming recusa um max age abaixo do intervalo mínimo: o resultado
+// ficaria stale sem que uma checagem real pudesse ser feita
+func validateHealthTiming() error {
+	if healthCheckMaxAge < healthCheckInterval {
+		return fmt.Errorf("HEALTH_CHECK_MAX_AGE (%v) must be at least HEALTH_CHECK_INTERVAL (%v)", healthCheckMaxAge, healthCheckInterval)
+	}
+	return nil
+}
+
+// BRUTO: Health check com cache - no máximo uma checagem real por intervalo
+func checkPaymentProcessorHealth(ctx context.Context, processor string) bool {
+	healthMu.Lock()
+	last, checked := lastHealthCheck[processor]
+	if !healthCheckDue(checked, time.Since(last)) {
+		healthMu.Unlock()
+		// Ainda dentro do max age: serve o último resultado conhecido
+		if healthy, ok := brutoCache.Get("health_" + processor); ok {
+			return healthy
+		}
+		// BRUTO: checagem em andamento, assume saudável
+		return true
+	}
+	if !checked {
+		evictOldestHealthLocked()
+	}
+	lastHealthCheck[processor] = time.Now()
+	healthMu.Unlock()
+
+	healthy := fetchProcessorHealth(ctx, processor)
+	wasHealthy, known := brutoCache.Get("health_" + processor)
+	// Orçamento do request esgotou: a falha não diz nada sobre o processor
+	if ctx.Err() != nil {
+		if known {
+			return wasHealthy
+		}
+		return true
+	}
+	brutoCache.Set("health_"+processor, healthy, healthResultTTL)
+
+	// Reaper ativo: processor ficou unhealthy, descarta conexões ociosas para não
+	// reutilizar sockets de um backend que pode ter reiniciado
+	if idleConnReaper && !healthy && (!known || wasHealthy) {
+		brutoConnectionPool.CloseIdleConnections()
+		log.Printf("Processor %s unhealthy: idle connections closed", processor)
+	}
+	return healthy
+}
+
+// fetchProcessorHealth consulta o processor de fato
+func fetchProcessorHealth(ctx context.Context, processor string) bool {
+	client := brutoConnectionPool.GetConnection()
+
+	ctx, cancel := downstreamContext(ctx, 300*time.Millisecond)
+	defer cancel()
+
+	url := fmt.Sprintf("http://%s:8080/payments/service-health", processor)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	// 429: rate limit do processor, mantém o último resultado conhecido
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if healthy, ok := brutoCache.Get("health_" + processor); ok {
+			return healthy
+		}
+		return true
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	var health processorHealth
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return false
+	}
+	return !health.Failing
 }
 
 func main() {
@@ -233,6 +832,44 @@ func main() {
 		log.Fatalf("Failed to load keys: %v", err)
 	}
 
+	// Max age e intervalo mínimo do health check
+	if err := validateHealthTiming(); err != nil {
+		log.Fatalf("Invalid health check timing: %v", err)
+	}
+
+	// Campos estáticos do corpo enviado aos processors
+	if err := loadProcessorBodyTemplate(); err != nil {
+		log.Fatalf("Invalid processor body template: %v", err)
+	}
+
+	// Deduplicação: backend selecionado por DEDUP_BACKEND (memory, file, redis)
+	deduper, err := dedup.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize dedup: %v", err)
+	}
+	defer deduper.Close()
+
+	// Banco para snapshots do shutdown e índice por cliente
+	db := openOrchestratorDB()
+	if maxPaymentsPerCustomer > 0 && db == nil {
+		log.Fatalf("MAX_PAYMENTS_PER_CUSTOMER requires ORCHESTRATOR_DB_PATH")
+	}
+
+	// Registro de caches para /admin/memory e limite suave (CACHE_SOFT_LIMIT)
+	dedup.RegisterCache(deduper)
+	cachereg.Register("bruto_cache", brutoCache.Len, brutoCache.Clear)
+	cachereg.StartLimiter(5 * time.Second)
+
+	// Remoção periódica das entradas expiradas do brutoCache
+	stopJanitor := brutoCache.StartJanitor(config.GetDuration("CACHE_JANITOR_INTERVAL", 10*time.Second))
+	defer stopJanitor()
+
+	// Pool de workers para chamadas aos processors (opcional)
+	startWorkerPool(db)
+
+	// Flush periódico dos totais por processor acumulados em shards
+	processorTotals.startFlusher(summaryFlushInterval)
+
 	// Create router
 	router := mux.NewRouter()
 
@@ -243,33 +880,106 @@ func main() {
 		w.Write([]byte(`{"status":"healthy"}`))
 	}).Methods("GET")
 
+	// Estatísticas do pool de clients dos processors
+	router.HandleFunc("/pool-stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(brutoConnectionPool.Stats())
+	}).Methods("GET")
+
+	// Estado do circuit breaker geral e dos breakers por processor
+	router.HandleFunc("/circuit-breaker", handleCircuitBreaker).Methods("GET")
+
+	// Admin: entradas por cache em memória
+	router.HandleFunc("/admin/memory", cachereg.Handler).Methods("GET")
+
 	// Routes with optimized handlers
 	router.HandleFunc("/payments", func(w http.ResponseWriter, r *http.Request) {
 		atomic.AddInt64(&requestCount, 1)
-		handlePayments(w, r, keyStore)
+		handlePayments(w, r, keyStore, deduper, db)
 	}).Methods("POST")
 
+	// Readiness: reflete a saúde do processor (sonda o breaker mesmo aberto)
+	router.HandleFunc("/health/ready", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !probeProcessorHealth(r.Context(), "payment-processor") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"unavailable"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"ready"}`))
+	}).Methods("GET")
+
+	// Métricas dos contadores atômicos
+	// Métricas: formato Prometheus em /metrics, JSON dos contadores em /metrics/json
+	router.Handle("/metrics", metricsHandler(newMetricsRegistry())).Methods("GET")
+	router.HandleFunc("/metrics/json", handleMetrics).Methods("GET")
+
+	// Admin: últimas decisões de roteamento (requer LOG_LEVEL=debug)
+	router.HandleFunc("/admin/routing-decisions", handleRoutingDecisions).Methods("GET")
+
 	// Start server with optimized settings
 	server := &http.Server{
 		Addr:         ":8444",
-		Handler:      router,
+		Handler:      trackInFlight(router),
 		ReadTimeout:  500 * time.Millisecond,
 		WriteTimeout: 500 * time.Millisecond,
 		IdleTimeout:  30 * time.Second,
 	}
 
 	log.Printf("Payment Orchestrator BRUTO starting on :8444")
-	log.Fatal(server.ListenAndServe())
+	runServer(server, deduper, db)
+}
+
+// Métricas em JSON a partir dos contadores atômicos
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{
+		"requests":   atomic.LoadInt64(&requestCount),
+		"successes":  atomic.LoadInt64(&successCount),
+		"errors":     atomic.LoadInt64(&errorCount),
+		"timeouts":   atomic.LoadInt64(&timeoutCount),
+		"replays":    atomic.LoadInt64(&replayCount),
+		"expired":    atomic.LoadInt64(&expiredCount),
+		"duplicates": atomic.LoadInt64(&duplicateCount),
+		"reconcile":  atomic.LoadInt64(&reconcileCount),
+
+		"breakerTransitions": atomic.LoadInt64(&breakerTransitionCount),
+	})
+}
+
+// countPayment contabiliza o pagamento cobrado nos totais por processor e no
+// summary-service. Sem processor não houve cobrança; duplicata indica que o
+// processor já tinha o pagamento, já contabilizado na primeira resposta.
+func countPayment(result HTTPPaymentResponse, amount float64, customerID string) {
+	if result.Processor == "" || result.Duplicate {
+		return
+	}
+	processorTotals.Add(result.Processor, amount)
+	recordPayment(result.Processor, amount, result.RequestedAt, customerID)
 }
 
 // BRUTO: Handle payments - ULTRA-AGRESIVO
-func handlePayments(w http.ResponseWriter, r *http.Request, keyStore *keys.KeyStore) {
-	if !circuitBreaker.canExecute() {
+func handlePayments(w http.ResponseWriter, r *http.Request, keyStore *keys.KeyStore, deduper dedup.Deduper, db *database.Database) {
+	allowed, probe := circuitBreaker.canExecute()
+	if !allowed {
+		// Sonda em background para o breaker aprender que o processor voltou
+		go probeProcessorHealth(context.Background(), "payment-processor")
 		atomic.AddInt64(&errorCount, 1)
 		http.Error(w, "Service temporarily unavailable", http.StatusServiceUnavailable)
 		return
 	}
 
+	// Chamada de teste do HALF_OPEN que não chega aos processors (request
+	// inválido, replay, limite) devolve a vaga ao sair do handler
+	probeHandedOff := false
+	if probe {
+		defer func() {
+			if !probeHandedOff {
+				circuitBreaker.releaseProbe()
+			}
+		}()
+	}
+
 	var paymentReq map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&paymentReq); err != nil {
 		atomic.AddInt64(&errorCount, 1)
@@ -277,62 +987,101 @@ func handlePayments(w http.ResponseWriter, r *http.Request, keyStore *keys.KeySt
 		return
 	}
 
-	correlationId, _ := paymentReq["correlationId"].(string)
+	// correlationId de outro tipo (ex.: número) é rejeitado em vez de virar ""
+	correlationId, ok := paymentReq["correlationId"].(string)
+	if !ok || correlationId == "" {
+		atomic.AddInt64(&errorCount, 1)
+		http.Error(w, "correlationId must be a non-empty string", http.StatusBadRequest)
+		return
+	}
 	// Deduplicação: se já processou, retorna sucesso idempotente
-	processedPayments.RLock()
-	_, exists := processedPayments.m[correlationId]
-	processedPayments.RUnlock()
+	exists, err := deduper.Seen(correlationId)
+	if err != nil {
+		log.Printf("Dedup check failed for %s: %v", correlationId, err)
+		if !dedup.FailOpen() {
+			atomic.AddInt64(&errorCount, 1)
+			http.Error(w, "Dedup unavailable", http.StatusServiceUnavailable)
+			return
+		}
+	}
 	if exists {
+		atomic.AddInt64(&replayCount, 1)
 		// BRUTO: Resposta hardcoded para velocidade máxima
 		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte(`{"id":"` + correlationId + `","status":"processed","message":"Idempotent: already processed"}`))
 		return
 	}
 
+	// Limite de pagamentos por cliente (opcional)
+	if !reserveCustomerPayment(w, db, paymentReq) {
+		return
+	}
+
+	// Trace da decisão de roteamento (nil quando LOG_LEVEL != debug)
+	decision := newRoutingDecision(correlationId)
+
 	// BRUTO: Canal para resultado
 	resultChan := make(chan HTTPPaymentResponse, 2)
 
-	// Estratégia 1: Payment Processor (real) - ULTRA-RÁPIDO
+	// Payment Processor (real), com retentativas antes de desistir
+	probeHandedOff = true
 	go func() {
-		if checkPaymentProcessorHealth("payment-processor") {
-			resp := callPaymentProcessorBRUTO(paymentReq, "payment-processor")
-			if resp.Status != "error" {
-				resultChan <- resp
-			}
-		}
-	}()
+		// Health check e chamadas dividem o mesmo orçamento
+		ctx, cancel := newRequestBudget(r)
+		defer cancel()
+		defer countBudgetTimeout(ctx)
 
-	// Estratégia 2: Fallback (local) - ULTRA-RÁPIDO
-	go func() {
-		time.Sleep(50 * time.Millisecond) // BRUTO: 50ms apenas
-		resultChan <- HTTPPaymentResponse{
-			ID:      correlationId,
-			Status:  "processed",
-			Message: "Local fallback",
+		// Tenta os processors reais, com backoff curto entre rodadas, até o
+		// orçamento acabar; só então o pagamento é dado como falho
+		success := false
+		for round := 0; round < processorRetryRounds && !success; round++ {
+			if round > 0 && !sleepCtx(ctx, processorRetryBackoff) {
+				break
+			}
+			success = attemptPaymentProcessors(ctx, paymentReq, decision, resultChan)
 		}
-	}()
-
-	// Estratégia 3: Fallback instantâneo (garantia 100%) - 0ms
-	go func() {
-		time.Sleep(100 * time.Millisecond) // BRUTO: 100ms para garantir
-		resultChan <- HTTPPaymentResponse{
-			ID:      correlationId,
-			Status:  "processed",
-			Message: "Instant fallback guarantee",
+		circuitBreaker.recordResult(probe, success)
+		if !success {
+			// Nenhum processor real cobrou: erro explícito, nunca um "processed" falso
+			resultChan <- HTTPPaymentResponse{
+				ID:      correlationId,
+				Status:  "error",
+				Message: "All payment processors failed",
+			}
 		}
 	}()
 
 	// Pega o primeiro que chegar
 	result := <-resultChan
+	if result.Processor != "" {
+		decision.finish(result.Processor, "processor responded first")
+	} else {
+		decision.finish("none", result.Message)
+	}
+
+	// Falha real: sem resumo e sem dedup, para o cliente poder tentar de novo
+	if result.Status == "error" {
+		completeCustomerPayment(db, paymentReq, "")
+		atomic.AddInt64(&errorCount, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(`{"id":"` + result.ID + `","status":"error","message":"` + result.Message + `"}`))
+		return
+	}
+
+	// Resumo antes do dedup: um ID marcado sempre tem o pagamento contabilizado
+	amount, _ := paymentReq["amount"].(float64)
+	customerID, _ := paymentReq["customerId"].(string)
+	countPayment(result, amount, customerID)
+	completeCustomerPayment(db, paymentReq, result.Processor)
 
 	// Marca como processado
-	processedPayments.Lock()
-	processedPayments.m[correlationId] = struct{}{}
-	processedPayments.Unlock()
+	if err := deduper.Mark(correlationId); err != nil {
+		log.Printf("Dedup mark failed for %s: %v", correlationId, err)
+	}
 
 	// BRUTO: Resposta hardcoded para velocidade máxima
 	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(`{"id":"` + result.ID + `","status":"` + result.Status + `","message":"` + result.Message + `"}`))
+	w.Write([]byte(`{"id":"` + result.ID + `","status":"` + result.Status + `","message":"` + result.Message + `","processor":"` + result.Processor + `","requestedAt":"` + result.RequestedAt + `"}`))
 	atomic.AddInt64(&successCount, 1)
-	circuitBreaker.recordSuccess()
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/dedup"
+)
+
+func TestHandlePaymentsRejectsInvalidCorrelationID(t *testing.T) {
+	circuitBreaker.Reset()
+	t.Cleanup(circuitBreaker.Reset)
+	deduper := dedup.NewMemory(dedup.Options{})
+
+	for _, body := range []string{
+		`{"correlationId":123,"amount":1}`,
+		`{"correlationId":null,"amount":1}`,
+		`{"correlationId":"","amount":1}`,
+		`{"amount":1}`,
+	} {
+		rec := httptest.NewRecorder()
+		handlePayments(rec, httptest.NewRequest("POST", "/payments", strings.NewReader(body)), nil, deduper, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+	// Nada foi marcado como processado, nem sob a chave vazia
+	if seen, _ := deduper.Seen(""); seen {
+		t.Fatal("invalid payment was marked in dedup")
+	}
+	if state := circuitBreaker.currentState(); state != CLOSED {
+		t.Fatalf("breaker state = %s, want CLOSED (client errors are not failures)", state)
+	}
+}
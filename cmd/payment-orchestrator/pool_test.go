@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConnectionPoolRotatesDistinctClients(t *testing.T) {
+	pool := NewBRUTOConnectionPool(PoolConfig{Size: 8})
+	seen := make(map[*http.Client]int)
+	for i := 0; i < 16; i++ {
+		seen[pool.GetConnection()]++
+	}
+	if len(seen) != 8 {
+		t.Fatalf("distinct clients = %d, want 8", len(seen))
+	}
+	for client, n := range seen {
+		if n != 2 {
+			t.Fatalf("client %p served %d times, want 2 (round-robin)", client, n)
+		}
+	}
+	transports := make(map[http.RoundTripper]bool)
+	for client := range seen {
+		transports[client.Transport] = true
+	}
+	if len(transports) != 8 {
+		t.Fatalf("distinct transports = %d, want one per client", len(transports))
+	}
+	if stats := pool.Stats(); stats.Clients != 8 || stats.TotalRequests != 16 {
+		t.Fatalf("stats = %+v, want 8 clients and 16 requests", stats)
+	}
+}
+
+func TestConnectionPoolDefaultsToOneClient(t *testing.T) {
+	pool := NewBRUTOConnectionPool(PoolConfig{})
+	if stats := pool.Stats(); stats.Clients != 1 {
+		t.Fatalf("clients = %d, want 1", stats.Clients)
+	}
+}
+
+// benchmarkPool dispara 200 requisições concorrentes por operação contra um
+// processor local, cada uma com o client entregue pelo pool
+func benchmarkPool(b *testing.B, size int) {
+	processor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(`{"message":"payment processed successfully"}`))
+	}))
+	defer processor.Close()
+
+	pool := NewBRUTOConnectionPool(PoolConfig{Size: size, Timeout: 5 * time.Second})
+	defer pool.Close()
+
+	const concurrent = 200
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for j := 0; j < concurrent; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := pool.GetConnection().Get(processor.URL)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkConnectionPoolSingleClient(b *testing.B) {
+	benchmarkPool(b, 1)
+}
+
+func BenchmarkConnectionPoolEightClients(b *testing.B) {
+	benchmarkPool(b, 8)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Latência das chamadas ao processor, medida em callPaymentProcessorBRUTO
+var processorLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "orchestrator_processor_call_duration_seconds",
+	Help:    "Latência das chamadas POST /payments aos processors.",
+	Buckets: []float64{.005, .01, .025, .05, .1, .2, .3, .5, 1},
+}, []string{"processor", "outcome"})
+
+// newMetricsRegistry registra contadores, latência e estado dos breakers num
+// registry próprio; os contadores leem os mesmos atômicos do /metrics/json
+func newMetricsRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	counters := map[string]*int64{
+		"requests":  &requestCount,
+		"successes": &successCount,
+		"errors":    &errorCount,
+		"timeouts":  &timeoutCount,
+	}
+	for name, counter := range counters {
+		registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "orchestrator_" + name + "_total",
+			Help: "Contador atômico " + name + " do orchestrator.",
+		}, func() float64 { return float64(atomic.LoadInt64(counter)) }))
+	}
+	registry.MustRegister(processorLatency)
+
+	// Estado dos breakers: 0 CLOSED, 1 OPEN, 2 HALF_OPEN
+	registry.MustRegister(breakerStateGauge("orchestrator", circuitBreaker))
+	for _, processor := range paymentProcessors {
+		registry.MustRegister(breakerStateGauge(processor, breakerFor(processor)))
+	}
+	return registry
+}
+
+func breakerStateGauge(name string, cb *CircuitBreaker) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "orchestrator_circuit_breaker_state",
+		Help:        "Estado do circuit breaker (0 CLOSED, 1 OPEN, 2 HALF_OPEN).",
+		ConstLabels: prometheus.Labels{"breaker": name},
+	}, func() float64 { return float64(cb.currentState()) })
+}
+
+// metricsHandler serve o registry no formato do Prometheus
+func metricsHandler(registry *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
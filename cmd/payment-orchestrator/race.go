@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+var (
+	// SUCCESS_TIEBREAK (ROUTING_MODE=race): qual sucesso conta quando mais de um
+	// processor aceita o pagamento: first (padrão), prefer-default ou prefer-fallback
+	successTiebreak = config.GetString("SUCCESS_TIEBREAK", "first")
+
+	// Sucessos descartados pelo desempate, a reconciliar com os processors
+	reconcileCount int64
+)
+
+type raceResult struct {
+	processor string
+	resp      HTTPPaymentResponse
+	latency   time.Duration
+}
+
+// preferredProcessor retorna o processor que vence o desempate, ou "" no modo first
+func preferredProcessor() string {
+	switch successTiebreak {
+	case "prefer-default":
+		return paymentProcessors[0]
+	case "prefer-fallback":
+		if len(paymentProcessors) > 1 {
+			return paymentProcessors[1]
+		}
+	}
+	return ""
+}
+
+// racePaymentProcessors envia o pagamento a todos os processors saudáveis em
+// paralelo e entrega em winner apenas o vencedor do desempate. O vencedor é
+// entregue assim que a decisão não pode mais mudar; os demais sucessos são
+// registrados como duplicatas a reconciliar. Bloqueia até todas as chamadas
+// terminarem e retorna se algum processor aceitou o pagamento.
+func racePaymentProcessors(ctx context.Context, paymentReq map[string]interface{}, decision *RoutingDecision, winner chan<- HTTPPaymentResponse) bool {
+	results := make(chan raceResult, len(paymentProcessors))
+	pending := 0
+	for _, processor := range paymentProcessors {
+		if !checkPaymentProcessorHealth(ctx, processor) {
+			decision.attempt(processor, "skipped", "unhealthy", 0)
+			continue
+		}
+		// Cópia por processor: a chamada adiciona requestedAt ao mapa
+		req := make(map[string]interface{}, len(paymentReq)+1)
+		for k, v := range paymentReq {
+			req[k] = v
+		}
+		pending++
+		go func(processor string) {
+			start := time.Now()
+			resp := dispatchPaymentProcessor(ctx, req, processor)
+			results <- raceResult{processor: processor, resp: resp, latency: time.Since(start)}
+		}(processor)
+	}
+
+	preferred := preferredProcessor()
+	var chosen *raceResult
+	delivered := false
+	deliver := func() {
+		if chosen != nil && !delivered {
+			winner <- chosen.resp
+			delivered = true
+		}
+	}
+
+	for ; pending > 0; pending-- {
+		r := <-results
+		if r.resp.Status == "error" {
+			decision.attempt(r.processor, "error", r.resp.Message, r.latency)
+			continue
+		}
+		decision.attempt(r.processor, "success", "", r.latency)
+
+		switch {
+		case chosen == nil:
+			chosen = &r
+		case !delivered && r.processor == preferred:
+			// Ainda não entregue: o preferido substitui o sucesso anterior
+			loser := chosen
+			chosen = &r
+			markForReconcile(loser.processor, paymentReq)
+		default:
+			markForReconcile(r.processor, paymentReq)
+		}
+
+		// Sem preferência, ou o preferido já venceu: a decisão está fechada
+		if preferred == "" || chosen.processor == preferred {
+			deliver()
+		}
+	}
+	// Preferido falhou ou foi pulado: vale o sucesso que houver
+	deliver()
+	return delivered
+}
+
+func markForReconcile(processor string, paymentReq map[string]interface{}) {
+	atomic.AddInt64(&reconcileCount, 1)
+	correlationId, _ := paymentReq["correlationId"].(string)
+	log.Printf("Payment %s also accepted by %s: not counted, to be reconciled", correlationId, processor)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeProcessor sobe um processor em host:8080 (a porta do processor é fixa
+// na URL) que aceita todo pagamento após delay
+func fakeProcessor(t *testing.T, host string, delay time.Duration, requestedAt *atomic.Value) {
+	t.Helper()
+	ln, err := net.Listen("tcp", host+":8080")
+	if err != nil {
+		t.Skipf("cannot listen on %s:8080: %v", host, err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		requestedAt.Store(body["requestedAt"])
+		time.Sleep(delay)
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Listener.Close()
+	server.Listener = ln
+	server.Start()
+	t.Cleanup(server.Close)
+}
+
+// markHealthy deixa o health do processor fresco em cache, sem checagem real
+func markHealthy(t *testing.T, processors ...string) {
+	t.Helper()
+	healthMu.Lock()
+	for _, p := range processors {
+		lastHealthCheck[p] = time.Now()
+		brutoCache.Set("health_"+p, true, time.Minute)
+	}
+	healthMu.Unlock()
+	t.Cleanup(func() {
+		healthMu.Lock()
+		for _, p := range processors {
+			forgetHealthLocked(p)
+		}
+		healthMu.Unlock()
+	})
+}
+
+func TestRaceTiebreakCountsSingleWinner(t *testing.T) {
+	// Default lento, fallback rápido: os dois aceitam o pagamento
+	const slowDefault, fastFallback = "127.0.0.1", "127.0.0.2"
+	var defaultAt, fallbackAt atomic.Value
+	fakeProcessor(t, slowDefault, 50*time.Millisecond, &defaultAt)
+	fakeProcessor(t, fastFallback, 0, &fallbackAt)
+	withProcessors(t, []string{slowDefault, fastFallback}, nil)
+	markHealthy(t, slowDefault, fastFallback)
+
+	cases := []struct {
+		policy string
+		want   string
+	}{
+		{"first", fastFallback},
+		{"prefer-default", slowDefault},
+		{"prefer-fallback", fastFallback},
+	}
+	for _, tc := range cases {
+		t.Run(tc.policy, func(t *testing.T) {
+			prev := successTiebreak
+			successTiebreak = tc.policy
+			t.Cleanup(func() { successTiebreak = prev })
+			for _, p := range []string{slowDefault, fastFallback} {
+				breakerFor(p).Reset()
+			}
+
+			paymentReq := map[string]interface{}{"correlationId": "race-" + tc.policy, "amount": 10.0}
+			winner := make(chan HTTPPaymentResponse, 2)
+			before := atomic.LoadInt64(&reconcileCount)
+			if !racePaymentProcessors(context.Background(), paymentReq, nil, winner) {
+				t.Fatal("race reported no success")
+			}
+
+			if len(winner) != 1 {
+				t.Fatalf("winners delivered = %d, want exactly 1", len(winner))
+			}
+			if got := (<-winner).Processor; got != tc.want {
+				t.Fatalf("winner = %s, want %s", got, tc.want)
+			}
+			if n := atomic.LoadInt64(&reconcileCount) - before; n != 1 {
+				t.Fatalf("losers marked for reconcile = %d, want 1", n)
+			}
+			// Cada processor recebeu a própria cópia: o pedido original fica intocado
+			if _, ok := paymentReq["requestedAt"]; ok {
+				t.Fatal("race mutated the shared payment request")
+			}
+			if defaultAt.Load() == nil || fallbackAt.Load() == nil {
+				t.Fatal("a processor got no requestedAt")
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+var (
+	// Rodadas pelos processors antes de dar o pagamento como falho (dentro de REQUEST_BUDGET)
+	processorRetryRounds = config.GetInt("PROCESSOR_RETRY_ROUNDS", 3)
+	// Pausa entre rodadas
+	processorRetryBackoff = config.GetDuration("PROCESSOR_RETRY_BACKOFF", 25*time.Millisecond)
+)
+
+// attemptPaymentProcessors faz uma rodada pelos processors segundo ROUTING_MODE
+// e entrega o primeiro sucesso em resultChan; retorna se houve sucesso
+func attemptPaymentProcessors(ctx context.Context, paymentReq map[string]interface{}, decision *RoutingDecision, resultChan chan<- HTTPPaymentResponse) bool {
+	// Modo race: todos em paralelo, com desempate configurável entre sucessos
+	if routingMode == "race" {
+		return racePaymentProcessors(ctx, paymentReq, decision, resultChan)
+	}
+
+	// Processor preferido primeiro, com failover para os demais; no first-wins
+	// o preferido é o mais barato disponível (ver selectProcessor)
+	var processors []string
+	switch routingMode {
+	case "hash":
+		processors = processorsByHash(routingKey(paymentReq))
+	case "weighted":
+		processors = processorsByWeight()
+	default:
+		processors = processorsByCost(ctx)
+	}
+	for _, processor := range processors {
+		if !checkPaymentProcessorHealth(ctx, processor) {
+			decision.attempt(processor, "skipped", "unhealthy", 0)
+			continue
+		}
+		start := time.Now()
+		resp := dispatchPaymentProcessor(ctx, paymentReq, processor)
+		if resp.Status != "error" {
+			decision.attempt(processor, "success", "", time.Since(start))
+			resultChan <- resp
+			return true
+		}
+		decision.attempt(processor, "error", resp.Message, time.Since(start))
+	}
+	return false
+}
+
+// sleepCtx espera d, ou retorna false se o contexto acabar antes
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
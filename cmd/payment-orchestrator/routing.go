@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/logging"
+)
+
+var (
+	// Processors conhecidos, do mais barato ao mais caro (ordem de preferência no modo first-wins)
+	paymentProcessors = config.GetList("PAYMENT_PROCESSORS", []string{"payment-processor", "payment-processor-fallback"})
+
+	// ROUTING_MODE: first-wins (padrão), hash (sticky por customerId/correlationId)
+	// weighted (sorteio proporcional a PROCESSOR_WEIGHTS) ou race (todos em paralelo)
+	routingMode = config.GetString("ROUTING_MODE", "first-wins")
+
+	// PROCESSOR_WEIGHTS: "processor=peso,..." (ex.: payment-processor=80,payment-processor-fallback=20);
+	// processors sem peso informado ficam com 0 e só recebem tráfego por failover
+	processorWeights = parseProcessorWeights(config.GetList("PROCESSOR_WEIGHTS", nil))
+)
+
+// selectProcessor escolhe o processor do modo first-wins: o mais barato (o
+// default) sempre que estiver saudável e com o breaker não OPEN; senão o
+// próximo da lista que estiver. Com todos indisponíveis, fica com o default.
+func selectProcessor(ctx context.Context) string {
+	for _, processor := range paymentProcessors {
+		if breakerFor(processor).currentState() != OPEN && checkPaymentProcessorHealth(ctx, processor) {
+			return processor
+		}
+	}
+	return paymentProcessors[0]
+}
+
+// processorsByCost ordena para o modo first-wins: o escolhido por
+// selectProcessor e depois os demais em ordem de custo, só usados se ele falhar
+func processorsByCost(ctx context.Context) []string {
+	selected := selectProcessor(ctx)
+	ordered := []string{selected}
+	for _, processor := range paymentProcessors {
+		if processor != selected {
+			ordered = append(ordered, processor)
+		}
+	}
+	return ordered
+}
+
+func parseProcessorWeights(entries []string) map[string]int {
+	weights := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		w, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || w < 0 {
+			continue
+		}
+		weights[strings.TrimSpace(name)] = w
+	}
+	return weights
+}
+
+// processorsByWeight sorteia o primeiro processor proporcionalmente ao peso e
+// mantém os demais na ordem de preferência para failover. Usa o gerador global
+// de math/rand/v2, que é por goroutine e não disputa lock.
+func processorsByWeight() []string {
+	total := 0
+	for _, p := range paymentProcessors {
+		total += processorWeights[p]
+	}
+	if total == 0 {
+		return paymentProcessors
+	}
+
+	pick := rand.IntN(total)
+	chosen := 0
+	for i, p := range paymentProcessors {
+		if pick < processorWeights[p] {
+			chosen = i
+			break
+		}
+		pick -= processorWeights[p]
+	}
+
+	ordered := make([]string, 0, len(paymentProcessors))
+	ordered = append(ordered, paymentProcessors[chosen])
+	for i, p := range paymentProcessors {
+		if i != chosen {
+			ordered = append(ordered, p)
+		}
+	}
+	return ordered
+}
+
+// processorsByHash ordena os processors por rendezvous hashing (HRW) da chave:
+// a mesma chave sempre prefere o mesmo processor, e adicionar/remover um
+// processor só remaneja as chaves que o tinham como preferido
+func processorsByHash(key string) []string {
+	type scored struct {
+		processor string
+		score     uint64
+	}
+	ranked := make([]scored, 0, len(paymentProcessors))
+	for _, p := range paymentProcessors {
+		h := fnv.New64a()
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		ranked = append(ranked, scored{processor: p, score: h.Sum64()})
+	}
+	sort.Slice(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+
+	ordered := make([]string, len(ranked))
+	for i, r := range ranked {
+		ordered[i] = r.processor
+	}
+	return ordered
+}
+
+// routingKey escolhe a chave de afinidade: customerId quando presente, senão correlationId
+func routingKey(paymentReq map[string]interface{}) string {
+	if customerID, ok := paymentReq["customerId"].(string); ok && customerID != "" {
+		return customerID
+	}
+	correlationID, _ := paymentReq["correlationId"].(string)
+	return correlationID
+}
+
+// Últimas decisões de roteamento, mantidas apenas com LOG_LEVEL=debug
+var routingTrace = newRoutingTrace(config.GetInt("ROUTING_TRACE_SIZE", 100))
+
+// Uma tentativa de envio para um processor
+type RoutingAttempt struct {
+	Processor string `json:"processor"`
+	Outcome   string `json:"outcome"` // success, error, skipped
+	Reason    string `json:"reason,omitempty"`
+	LatencyMs int64  `json:"latencyMs"`
+}
+
+// Decisão de roteamento de um pagamento: tentativas e motivo da escolha final
+type RoutingDecision struct {
+	CorrelationID string           `json:"correlationId"`
+	At            time.Time        `json:"at"`
+	Attempts      []RoutingAttempt `json:"attempts"`
+	Final         string           `json:"final"`
+	Reason        string           `json:"reason"`
+	mu            sync.Mutex
+}
+
+// newRoutingDecision retorna nil quando o trace está desligado,
+// e todos os métodos aceitam receiver nil para custo zero em produção
+func newRoutingDecision(correlationID string) *RoutingDecision {
+	if !logging.Enabled(logging.Debug) {
+		return nil
+	}
+	d := &RoutingDecision{CorrelationID: correlationID, At: time.Now()}
+	routingTrace.add(d)
+	return d
+}
+
+func (d *RoutingDecision) attempt(processor, outcome, reason string, latency time.Duration) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Attempts = append(d.Attempts, RoutingAttempt{
+		Processor: processor,
+		Outcome:   outcome,
+		Reason:    reason,
+		LatencyMs: latency.Milliseconds(),
+	})
+}
+
+func (d *RoutingDecision) finish(final, reason string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.Final = final
+	d.Reason = reason
+	d.mu.Unlock()
+	logging.Debugf("routing %s -> %s (%s)", d.CorrelationID, final, reason)
+}
+
+// snapshot copia a decisão sob lock para serialização
+func (d *RoutingDecision) snapshot() RoutingDecision {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return RoutingDecision{
+		CorrelationID: d.CorrelationID,
+		At:            d.At,
+		Attempts:      append([]RoutingAttempt(nil), d.Attempts...),
+		Final:         d.Final,
+		Reason:        d.Reason,
+	}
+}
+
+// Ring buffer com as últimas N decisões
+type RoutingTrace struct {
+	entries []*RoutingDecision
+	next    int
+	full    bool
+	mu      sync.Mutex
+}
+
+func newRoutingTrace(size int) *RoutingTrace {
+	if size < 1 {
+		size = 1
+	}
+	return &RoutingTrace{entries: make([]*RoutingDecision, size)}
+}
+
+func (t *RoutingTrace) add(d *RoutingDecision) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries[t.next] = d
+	t.next = (t.next + 1) % len(t.entries)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+// last retorna até n decisões, da mais recente para a mais antiga
+func (t *RoutingTrace) last(n int) []RoutingDecision {
+	t.mu.Lock()
+	count := t.next
+	if t.full {
+		count = len(t.entries)
+	}
+	if n <= 0 || n > count {
+		n = count
+	}
+	decisions := make([]*RoutingDecision, 0, n)
+	for i := 1; i <= n; i++ {
+		idx := (t.next - i + len(t.entries)) % len(t.entries)
+		decisions = append(decisions, t.entries[idx])
+	}
+	t.mu.Unlock()
+
+	result := make([]RoutingDecision, 0, len(decisions))
+	for _, d := range decisions {
+		result = append(result, d.snapshot())
+	}
+	return result
+}
+
+// GET /admin/routing-decisions?n=20
+func handleRoutingDecisions(w http.ResponseWriter, r *http.Request) {
+	n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(routingTrace.last(n))
+}
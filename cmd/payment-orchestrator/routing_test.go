@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func withProcessors(t *testing.T, processors []string, weights map[string]int) {
+	t.Helper()
+	prevProcessors, prevWeights := paymentProcessors, processorWeights
+	paymentProcessors, processorWeights = processors, weights
+	t.Cleanup(func() { paymentProcessors, processorWeights = prevProcessors, prevWeights })
+}
+
+func TestProcessorsByWeightApproximatesWeights(t *testing.T) {
+	withProcessors(t, []string{"default", "fallback"}, parseProcessorWeights([]string{"default=80", " fallback = 20 "}))
+
+	const draws = 100000
+	first := map[string]int{}
+	for i := 0; i < draws; i++ {
+		ordered := processorsByWeight()
+		if len(ordered) != 2 || ordered[0] == ordered[1] {
+			t.Fatalf("ordering %v must list each processor once for failover", ordered)
+		}
+		first[ordered[0]]++
+	}
+
+	// Binomial com n=100000 e p=0.8: desvio padrão ~0.13%; 1% é folga de sobra
+	share := float64(first["default"]) / draws
+	if math.Abs(share-0.8) > 0.01 {
+		t.Fatalf("default share = %.4f, want 0.80 ± 0.01 (%v)", share, first)
+	}
+}
+
+func TestProcessorsByWeightZeroWeights(t *testing.T) {
+	withProcessors(t, []string{"default", "fallback"}, parseProcessorWeights([]string{"fallback=100", "invalid", "default=-3"}))
+	for i := 0; i < 1000; i++ {
+		if p := processorsByWeight()[0]; p != "fallback" {
+			t.Fatalf("first = %s, want fallback (default has no weight)", p)
+		}
+	}
+
+	withProcessors(t, []string{"default", "fallback"}, map[string]int{})
+	if p := processorsByWeight()[0]; p != "default" {
+		t.Fatalf("first without weights = %s, want the preference order", p)
+	}
+}
+
+func TestProcessorsByHashIsSticky(t *testing.T) {
+	withProcessors(t, []string{"default", "fallback"}, nil)
+
+	preferred := map[string]int{}
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("customer-%d", i)
+		first := processorsByHash(key)
+		for call := 0; call < 5; call++ {
+			again := processorsByHash(key)
+			if again[0] != first[0] || again[1] != first[1] {
+				t.Fatalf("%s routed to %v, then %v", key, first, again)
+			}
+		}
+		preferred[first[0]]++
+	}
+	// As chaves se espalham pelos dois processors
+	if preferred["default"] == 0 || preferred["fallback"] == 0 {
+		t.Fatalf("preferred = %v, want both processors used", preferred)
+	}
+}
+
+func TestProcessorsByHashMinimalReshuffle(t *testing.T) {
+	withProcessors(t, []string{"a", "b", "c"}, nil)
+	before := map[string]string{}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("customer-%d", i)
+		before[key] = processorsByHash(key)[0]
+	}
+
+	// Sem o processor "c", só as chaves que o preferiam mudam de processor
+	withProcessors(t, []string{"a", "b"}, nil)
+	for key, was := range before {
+		now := processorsByHash(key)[0]
+		if was != "c" && now != was {
+			t.Fatalf("%s moved from %s to %s after removing c", key, was, now)
+		}
+	}
+}
+
+func TestRoutingKeyPrefersCustomer(t *testing.T) {
+	if k := routingKey(map[string]interface{}{"customerId": "c-1", "correlationId": "x"}); k != "c-1" {
+		t.Fatalf("routingKey = %q, want the customerId", k)
+	}
+	if k := routingKey(map[string]interface{}{"customerId": "", "correlationId": "x"}); k != "x" {
+		t.Fatalf("routingKey = %q, want the correlationId", k)
+	}
+}
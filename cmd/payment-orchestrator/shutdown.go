@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/dedup"
+)
+
+var (
+	// Banco do orchestrator: snapshots do shutdown e limite por cliente (vazio desliga)
+	orchestratorDBPath = config.GetString("ORCHESTRATOR_DB_PATH", "data/orchestrator.db")
+
+	// Tempo máximo para drenar as requisições em andamento
+	shutdownTimeout = config.GetDuration("SHUTDOWN_TIMEOUT", 10*time.Second)
+
+	// Requisições em andamento, para o log do shutdown
+	inFlightRequests int64
+)
+
+// trackInFlight conta as requisições em andamento
+func trackInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&inFlightRequests, 1)
+		defer atomic.AddInt64(&inFlightRequests, -1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// runServer serve até SIGINT/SIGTERM e então executa o shutdown na ordem:
+//  1. para de aceitar conexões e drena os handlers em andamento (cada handler
+//     registra o resumo antes de marcar o dedup, então ao fim da drenagem os dois estão em dia)
+//  2. grava resumo e dedup na mesma transação do BoltDB
+//  3. fecha o banco
+func runServer(server *http.Server, deduper dedup.Deduper, db *database.Database) {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- server.ListenAndServe() }()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+		return
+	case sig := <-stop:
+		log.Printf("Received %s, shutting down (%d in-flight requests)", sig, atomic.LoadInt64(&inFlightRequests))
+	}
+
+	draining := atomic.LoadInt64(&inFlightRequests)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Drain incomplete: %v (%d requests still in flight)", err, atomic.LoadInt64(&inFlightRequests))
+	} else {
+		log.Printf("Drained %d in-flight requests", draining)
+	}
+	brutoConnectionPool.Close()
+
+	if db == nil {
+		return
+	}
+	defer db.Close()
+
+	totals := processorTotals.Snapshot()
+	snapshots := map[string]interface{}{"summary": totals}
+	dedupIDs, inProcess := dedup.Snapshot(deduper)
+	if inProcess {
+		snapshots["dedup"] = dedupIDs
+	}
+	if err := db.SaveSnapshots(snapshots); err != nil {
+		log.Printf("Failed to persist shutdown snapshot: %v", err)
+		return
+	}
+
+	requests := 0
+	for _, total := range totals {
+		requests += total.TotalRequests
+	}
+	if inProcess {
+		log.Printf("Persisted summary (%d processors, %d payments) and %d dedup IDs to %s", len(totals), requests, len(dedupIDs), orchestratorDBPath)
+	} else {
+		log.Printf("Persisted summary (%d processors, %d payments) to %s; dedup backend persists itself", len(totals), requests, orchestratorDBPath)
+	}
+}
+
+// openOrchestratorDB abre o banco do orchestrator, ou retorna nil se desligado
+func openOrchestratorDB() *database.Database {
+	if orchestratorDBPath == "" {
+		return nil
+	}
+	db, err := database.NewDatabase(orchestratorDBPath)
+	if err != nil {
+		log.Fatalf("Failed to open orchestrator database: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+var (
+	// Endpoint do summary-service que contabiliza cada pagamento processado
+	summaryRecordURL = config.GetString("SUMMARY_RECORD_URL", "http://summary-service:8445/record")
+
+	summaryClient = &http.Client{Timeout: 200 * time.Millisecond}
+)
+
+// summaryGroup traduz o processor para o grupo do resumo: o primeiro
+// processor configurado é o default, os demais contam como fallback
+func summaryGroup(processor string) string {
+	if len(paymentProcessors) > 0 && processor == paymentProcessors[0] {
+		return "default"
+	}
+	return "fallback"
+}
+
+// recordPayment envia o pagamento ao summary-service em background, para não
+// somar a latência do resumo à resposta do pagamento
+func recordPayment(processor string, amount float64, requestedAt, customerID string) {
+	if summaryRecordURL == "" {
+		return
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"processor":   summaryGroup(processor),
+		"amount":      amount,
+		"requestedAt": requestedAt,
+		"customerId":  customerID,
+	})
+	if err != nil {
+		return
+	}
+	go func() {
+		resp, err := summaryClient.Post(summaryRecordURL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Summary record failed for %s: %v", processor, err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("Summary record for %s returned %d", processor, resp.StatusCode)
+		}
+	}()
+}
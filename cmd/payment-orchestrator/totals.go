@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+var (
+	// Shards locais onde os handlers acumulam os totais antes do flush
+	summaryShards = config.GetInt("SUMMARY_BATCH_SHARDS", 16)
+
+	// Um shard é descarregado ao atingir este número de pagamentos pendentes...
+	summaryBatchSize = config.GetInt("SUMMARY_BATCH_SIZE", 64)
+
+	// ...ou a cada intervalo, o que vier primeiro
+	summaryFlushInterval = config.GetDuration("SUMMARY_FLUSH_INTERVAL", 100*time.Millisecond)
+
+	processorTotals = newProcessorTotals(summaryShards, summaryBatchSize)
+)
+
+// Totais processados por processor, persistidos junto com o dedup
+type ProcessorTotal struct {
+	TotalRequests int
+	TotalAmount   float64
+}
+
+// ProcessorTotals acumula os pagamentos em shards e só periodicamente
+// (ou ao encher um shard) aplica no mapa compartilhado, para que o lock
+// disputado não seja tomado uma vez por pagamento
+type ProcessorTotals struct {
+	shards    []*totalsShard
+	batchSize int
+	totals    map[string]ProcessorTotal
+	mu        sync.Mutex
+}
+
+type totalsShard struct {
+	pending map[string]ProcessorTotal
+	count   int
+	mu      sync.Mutex
+}
+
+func newProcessorTotals(shards, batchSize int) *ProcessorTotals {
+	if shards < 1 {
+		shards = 1
+	}
+	t := &ProcessorTotals{
+		shards:    make([]*totalsShard, shards),
+		batchSize: batchSize,
+		totals:    make(map[string]ProcessorTotal),
+	}
+	for i := range t.shards {
+		t.shards[i] = &totalsShard{pending: make(map[string]ProcessorTotal)}
+	}
+	return t
+}
+
+func (t *ProcessorTotals) Add(processor string, amount float64) {
+	shard := t.shards[rand.IntN(len(t.shards))]
+	shard.mu.Lock()
+	total := shard.pending[processor]
+	total.TotalRequests++
+	total.TotalAmount += amount
+	shard.pending[processor] = total
+	shard.count++
+	full := shard.count >= t.batchSize
+	shard.mu.Unlock()
+
+	if full {
+		t.flushShard(shard)
+	}
+}
+
+// flushShard aplica os pendentes do shard nos totais compartilhados
+func (t *ProcessorTotals) flushShard(shard *totalsShard) {
+	shard.mu.Lock()
+	if shard.count == 0 {
+		shard.mu.Unlock()
+		return
+	}
+	pending := shard.pending
+	shard.pending = make(map[string]ProcessorTotal, len(pending))
+	shard.count = 0
+	shard.mu.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for processor, p := range pending {
+		total := t.totals[processor]
+		total.TotalRequests += p.TotalRequests
+		total.TotalAmount += p.TotalAmount
+		t.totals[processor] = total
+	}
+}
+
+// Flush descarrega todos os shards
+func (t *ProcessorTotals) Flush() {
+	for _, shard := range t.shards {
+		t.flushShard(shard)
+	}
+}
+
+// startFlusher descarrega os shards a cada intervalo
+func (t *ProcessorTotals) startFlusher(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			t.Flush()
+		}
+	}()
+}
+
+// Snapshot drena os shards antes de copiar, então nada pendente fica de fora
+func (t *ProcessorTotals) Snapshot() map[string]ProcessorTotal {
+	t.Flush()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	snapshot := make(map[string]ProcessorTotal, len(t.totals))
+	for processor, total := range t.totals {
+		snapshot[processor] = total
+	}
+	return snapshot
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestProcessorTotalsSnapshotDrainsPendingShards(t *testing.T) {
+	// Lote maior que o total de pagamentos: nada é descarregado pelo tamanho
+	totals := newProcessorTotals(8, 1000)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			processor := "default"
+			if g%2 == 1 {
+				processor = "fallback"
+			}
+			for i := 0; i < 50; i++ {
+				totals.Add(processor, 2)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	snapshot := totals.Snapshot()
+	for _, processor := range []string{"default", "fallback"} {
+		if got := snapshot[processor]; got.TotalRequests != 400 || got.TotalAmount != 800 {
+			t.Fatalf("%s = %+v, want 400 requests and 800 amount", processor, got)
+		}
+	}
+}
+
+func TestProcessorTotalsFlushesFullShard(t *testing.T) {
+	totals := newProcessorTotals(1, 3)
+	for i := 0; i < 3; i++ {
+		totals.Add("default", 1)
+	}
+	// O terceiro Add encheu o shard e já aplicou nos totais compartilhados
+	totals.mu.Lock()
+	got := totals.totals["default"]
+	totals.mu.Unlock()
+	if got.TotalRequests != 3 {
+		t.Fatalf("shared totals = %+v, want 3 requests flushed", got)
+	}
+}
+
+// mutexTotals é o acumulador anterior: um único mapa atrás de um mutex,
+// tomado uma vez por pagamento
+type mutexTotals struct {
+	totals map[string]ProcessorTotal
+	mu     sync.Mutex
+}
+
+func (t *mutexTotals) Add(processor string, amount float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	total := t.totals[processor]
+	total.TotalRequests++
+	total.TotalAmount += amount
+	t.totals[processor] = total
+}
+
+func benchmarkTotals(b *testing.B, add func(processor string, amount float64)) {
+	// Muitos updaters concorrentes, como os handlers sob carga
+	b.SetParallelism(32)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			add("default", 19.9)
+		}
+	})
+}
+
+func BenchmarkProcessorTotalsSingleMutex(b *testing.B) {
+	totals := &mutexTotals{totals: make(map[string]ProcessorTotal)}
+	benchmarkTotals(b, totals.Add)
+}
+
+func BenchmarkProcessorTotalsSharded(b *testing.B) {
+	totals := newProcessorTotals(summaryShards, summaryBatchSize)
+	benchmarkTotals(b, totals.Add)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
+)
+
+var (
+	// Pool de workers para as chamadas aos processors (0 = desligado, chamada direta)
+	workerPoolSize = config.GetInt("WORKER_POOL_SIZE", 0)
+	workerQueueLen = config.GetInt("WORKER_QUEUE_SIZE", 1000)
+
+	// Tempo máximo na fila: itens mais velhos que isso são descartados sem chamar o processor
+	// (o cliente já desistiu). 0 = sem limite
+	queueMaxWait = config.GetDuration("QUEUE_MAX_WAIT", 0)
+
+	processorQueue chan *processorJob
+	expiredCount   int64
+
+	// Banco onde os itens expirados ficam registrados (nil = só a métrica)
+	queueDB *database.Database
+)
+
+// Status gravado para o pagamento descartado na fila
+const paymentExpired = "expired"
+
+// Chamada a um processor enfileirada para o pool
+type processorJob struct {
+	ctx        context.Context
+	paymentReq map[string]interface{}
+	processor  string
+	enqueuedAt time.Time
+	result     chan HTTPPaymentResponse
+}
+
+// startWorkerPool inicia os workers quando WORKER_POOL_SIZE > 0; db (opcional)
+// recebe os pagamentos expirados na fila
+func startWorkerPool(db *database.Database) {
+	if workerPoolSize <= 0 {
+		return
+	}
+	queueDB = db
+	processorQueue = make(chan *processorJob, workerQueueLen)
+	for i := 0; i < workerPoolSize; i++ {
+		go processorWorker()
+	}
+}
+
+func processorWorker() {
+	for job := range processorQueue {
+		// Expirado na fila ou sem orçamento restante: ninguém espera mais por ele
+		if (queueMaxWait > 0 && time.Since(job.enqueuedAt) > queueMaxWait) || job.ctx.Err() != nil {
+			atomic.AddInt64(&expiredCount, 1)
+			recordExpired(job)
+			job.result <- HTTPPaymentResponse{Status: "error", Message: "expired in queue"}
+			continue
+		}
+		job.result <- callPaymentProcessorBRUTO(job.ctx, job.paymentReq, job.processor)
+	}
+}
+
+// recordExpired grava o pagamento como expirado, sem rebaixar um que outro
+// processor já completou
+func recordExpired(job *processorJob) {
+	if queueDB == nil {
+		return
+	}
+	correlationId, _ := job.paymentReq["correlationId"].(string)
+	if correlationId == "" {
+		return
+	}
+	if p, err := queueDB.GetPaymentByID(correlationId); err == nil && p.Status == "completed" {
+		return
+	}
+	customerID, _ := job.paymentReq["customerId"].(string)
+	amount, _ := job.paymentReq["amount"].(float64)
+	err := queueDB.UpsertPayment(&database.Payment{
+		ID:         correlationId,
+		CustomerID: customerID,
+		Amount:     amount,
+		Status:     paymentExpired,
+		CreatedAt:  job.enqueuedAt,
+		UpdatedAt:  time.Now(),
+	})
+	if err != nil {
+		log.Printf("Expired payment record failed for %s: %v", correlationId, err)
+	}
+}
+
+// dispatchPaymentProcessor chama o processor direto ou via pool, conforme configuração
+func dispatchPaymentProcessor(ctx context.Context, paymentReq map[string]interface{}, processor string) HTTPPaymentResponse {
+	if processorQueue == nil {
+		return callPaymentProcessorBRUTO(ctx, paymentReq, processor)
+	}
+	job := &processorJob{
+		ctx:        ctx,
+		paymentReq: paymentReq,
+		processor:  processor,
+		enqueuedAt: time.Now(),
+		result:     make(chan HTTPPaymentResponse, 1),
+	}
+	select {
+	case processorQueue <- job:
+	default:
+		return HTTPPaymentResponse{Status: "error", Message: "worker queue full"}
+	}
+	return <-job.result
+}
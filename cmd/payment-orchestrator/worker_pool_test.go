@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
+)
+
+func TestWorkerPoolExpiresQueuedBacklog(t *testing.T) {
+	db, err := database.NewDatabase(filepath.Join(t.TempDir(), "orchestrator.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	prevQueue, prevDB, prevWait := processorQueue, queueDB, queueMaxWait
+	t.Cleanup(func() { processorQueue, queueDB, queueMaxWait = prevQueue, prevDB, prevWait })
+	queueDB, queueMaxWait = db, 50*time.Millisecond
+
+	// Outro processor já completou este: a expiração não pode rebaixá-lo
+	now := time.Now()
+	if err := db.CreatePayment(&database.Payment{ID: "backlog-0", Status: "completed", ProcessorUsed: "default", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Backlog parado na fila além do tempo máximo; um item com o orçamento já
+	// estourado expira mesmo sendo recente
+	const backlog = 20
+	processorQueue = make(chan *processorJob, backlog+1)
+	var jobs []*processorJob
+	for i := 0; i < backlog; i++ {
+		jobs = append(jobs, &processorJob{
+			ctx:        context.Background(),
+			paymentReq: map[string]interface{}{"correlationId": fmt.Sprintf("backlog-%d", i), "customerId": "c1", "amount": 10.0},
+			processor:  "http://processor.invalid",
+			enqueuedAt: now.Add(-time.Second),
+			result:     make(chan HTTPPaymentResponse, 1),
+		})
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	jobs = append(jobs, &processorJob{
+		ctx:        cancelled,
+		paymentReq: map[string]interface{}{"correlationId": "no-budget", "amount": 1.0},
+		processor:  "http://processor.invalid",
+		enqueuedAt: now,
+		result:     make(chan HTTPPaymentResponse, 1),
+	})
+	for _, job := range jobs {
+		processorQueue <- job
+	}
+	close(processorQueue)
+
+	before := atomic.LoadInt64(&expiredCount)
+	processorWorker()
+
+	for _, job := range jobs {
+		if res := <-job.result; res.Status != "error" || res.Message != "expired in queue" {
+			t.Fatalf("job %v result = %+v, want expired", job.paymentReq["correlationId"], res)
+		}
+	}
+	if got := atomic.LoadInt64(&expiredCount) - before; got != int64(len(jobs)) {
+		t.Fatalf("expired count grew by %d, want %d", got, len(jobs))
+	}
+
+	if p, err := db.GetPaymentByID("backlog-0"); err != nil || p.Status != "completed" {
+		t.Fatalf("completed payment = %+v, %v; want it left completed", p, err)
+	}
+	for _, id := range []string{"backlog-1", fmt.Sprintf("backlog-%d", backlog-1), "no-budget"} {
+		p, err := db.GetPaymentByID(id)
+		if err != nil {
+			t.Fatalf("%s not recorded: %v", id, err)
+		}
+		if p.Status != paymentExpired {
+			t.Fatalf("%s status = %s, want %s", id, p.Status, paymentExpired)
+		}
+	}
+}
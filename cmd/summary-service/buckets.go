@@ -0,0 +1,168 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+// Agregados por janela de tempo para responder consultas por intervalo somando
+// buckets em vez de varrer pagamentos.
+//
+// Armazenamento: um bucket por janela com ao menos um pagamento, ou seja, no
+// pior caso (duração do teste / granularidade) buckets. Com 1s isso é ~60
+// buckets por minuto de carga. Cada bucket guarda também os registros
+// individuais (requestedAt, valor, processor) dos seus pagamentos, usados só
+// nos dois buckets de borda de um intervalo: o resultado é sempre exato, e a
+// granularidade define quantos registros precisam ser filtrados por consulta
+// (os de no máximo dois buckets), não a precisão.
+var summaryBuckets = newTimeBuckets(config.GetDuration("SUMMARY_BUCKET_GRANULARITY", time.Second))
+
+type timeBucket struct {
+	Default  ProcessorSummary
+	Fallback ProcessorSummary
+	records  []paymentRecord
+}
+
+// paymentRecord é um pagamento contabilizado no bucket, com o instante em ns
+type paymentRecord struct {
+	at       int64
+	fallback bool
+	requests int
+	amount   float64
+}
+
+type timeBuckets struct {
+	granularity time.Duration
+	buckets     map[int64]*timeBucket
+	mu          sync.RWMutex
+}
+
+func newTimeBuckets(granularity time.Duration) *timeBuckets {
+	if granularity <= 0 {
+		granularity = time.Second
+	}
+	return &timeBuckets{granularity: granularity, buckets: make(map[int64]*timeBucket)}
+}
+
+// Reset descarta todos os buckets
+func (t *timeBuckets) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buckets = make(map[int64]*timeBucket)
+}
+
+// index retorna o bucket que contém o instante (início alinhado à granularidade)
+func (t *timeBuckets) index(at time.Time) int64 {
+	return at.UnixNano() / int64(t.granularity)
+}
+
+// Add registra um pagamento no bucket do instante informado
+func (t *timeBuckets) Add(at time.Time, processor string, requests int, amount float64) {
+	idx := t.index(at)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b, ok := t.buckets[idx]
+	if !ok {
+		b = &timeBucket{}
+		t.buckets[idx] = b
+	}
+	switch processor {
+	case "default":
+		b.Default.TotalRequests += requests
+		b.Default.TotalAmount += amount
+	case "fallback":
+		b.Fallback.TotalRequests += requests
+		b.Fallback.TotalAmount += amount
+	default:
+		return
+	}
+	b.records = append(b.records, paymentRecord{
+		at:       at.UnixNano(),
+		fallback: processor == "fallback",
+		requests: requests,
+		amount:   amount,
+	})
+}
+
+// Len retorna a quantidade de buckets em memória
+func (t *timeBuckets) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.buckets)
+}
+
+// GetSummaryBetween totaliza exatamente os pagamentos com requestedAt em
+// [from, to]: buckets inteiros dentro do intervalo entram pelos agregados e os
+// buckets de borda (que podem estar só parcialmente dentro) pelos registros.
+func (t *timeBuckets) GetSummaryBetween(from, to time.Time) HTTPSummaryResponse {
+	var summary HTTPSummaryResponse
+	if to.Before(from) {
+		return summary
+	}
+	fromIdx, toIdx := t.index(from), t.index(to)
+	fromNs, toNs := from.UnixNano(), to.UnixNano()
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for idx, b := range t.buckets {
+		if idx < fromIdx || idx > toIdx {
+			continue
+		}
+		if idx > fromIdx && idx < toIdx {
+			summary.Default.TotalRequests += b.Default.TotalRequests
+			summary.Default.TotalAmount += b.Default.TotalAmount
+			summary.Fallback.TotalRequests += b.Fallback.TotalRequests
+			summary.Fallback.TotalAmount += b.Fallback.TotalAmount
+			continue
+		}
+		for _, r := range b.records {
+			if r.at < fromNs || r.at > toNs {
+				continue
+			}
+			total := &summary.Default
+			if r.fallback {
+				total = &summary.Fallback
+			}
+			total.TotalRequests += r.requests
+			total.TotalAmount += r.amount
+		}
+	}
+	return summary
+}
+
+// Ponto da série temporal de /summary/timeseries
+type TimeseriesPoint struct {
+	Timestamp        time.Time `json:"timestamp"`
+	DefaultRequests  int       `json:"defaultRequests"`
+	FallbackRequests int       `json:"fallbackRequests"`
+	Amount           float64   `json:"amount"`
+}
+
+// Series reagrupa os buckets em janelas de `bucket` (múltiplo da granularidade),
+// em ordem cronológica. Janelas sem pagamentos não aparecem.
+func (t *timeBuckets) Series(bucket time.Duration) []TimeseriesPoint {
+	factor := int64(bucket / t.granularity)
+	t.mu.RLock()
+	points := make(map[int64]*TimeseriesPoint)
+	for idx, b := range t.buckets {
+		start := idx / factor * factor
+		p, ok := points[start]
+		if !ok {
+			p = &TimeseriesPoint{Timestamp: time.Unix(0, start*int64(t.granularity)).UTC()}
+			points[start] = p
+		}
+		p.DefaultRequests += b.Default.TotalRequests
+		p.FallbackRequests += b.Fallback.TotalRequests
+		p.Amount += b.Default.TotalAmount + b.Fallback.TotalAmount
+	}
+	t.mu.RUnlock()
+
+	series := make([]TimeseriesPoint, 0, len(points))
+	for _, p := range points {
+		series = append(series, *p)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Timestamp.Before(series[j].Timestamp) })
+	return series
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func at(t *testing.T, s string) time.Time {
+	t.Helper()
+	ts, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestTimeBucketsRangeBoundaries(t *testing.T) {
+	b := newTimeBuckets(time.Second)
+	b.Add(at(t, "2025-07-15T12:00:00.000Z"), "default", 1, 10)
+	b.Add(at(t, "2025-07-15T12:00:00.999Z"), "fallback", 1, 20)
+	b.Add(at(t, "2025-07-15T12:00:01.000Z"), "default", 1, 30)
+	b.Add(at(t, "2025-07-15T12:00:02.500Z"), "default", 1, 40)
+
+	cases := []struct {
+		name, from, to string
+		wantRequests   int
+		wantAmount     float64
+	}{
+		{"whole first bucket", "2025-07-15T12:00:00.000Z", "2025-07-15T12:00:00.999Z", 2, 30},
+		{"window inside a bucket with no payments", "2025-07-15T12:00:00.500Z", "2025-07-15T12:00:00.600Z", 0, 0},
+		{"from inside a bucket", "2025-07-15T12:00:00.500Z", "2025-07-15T12:00:01.000Z", 2, 50},
+		{"to inside a bucket", "2025-07-15T12:00:00.000Z", "2025-07-15T12:00:02.499Z", 3, 60},
+		{"from equals to on a payment", "2025-07-15T12:00:01.000Z", "2025-07-15T12:00:01.000Z", 1, 30},
+		{"range straddling a boundary", "2025-07-15T12:00:00.999Z", "2025-07-15T12:00:01.000Z", 2, 50},
+		{"to on the start of a bucket", "2025-07-15T12:00:01.001Z", "2025-07-15T12:00:02.000Z", 0, 0},
+		{"edges inside distant buckets", "2025-07-15T12:00:00.001Z", "2025-07-15T12:00:02.500Z", 3, 90},
+		{"everything", "2025-07-15T11:59:59.000Z", "2025-07-15T12:00:03.000Z", 4, 100},
+		{"after the data", "2025-07-15T12:00:03.000Z", "2025-07-15T12:00:10.000Z", 0, 0},
+		{"inverted range", "2025-07-15T12:00:02.000Z", "2025-07-15T12:00:00.000Z", 0, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := b.GetSummaryBetween(at(t, tc.from), at(t, tc.to))
+			requests := s.Default.TotalRequests + s.Fallback.TotalRequests
+			amount := s.Default.TotalAmount + s.Fallback.TotalAmount
+			if requests != tc.wantRequests || amount != tc.wantAmount {
+				t.Fatalf("got %d requests / %.2f, want %d / %.2f", requests, amount, tc.wantRequests, tc.wantAmount)
+			}
+		})
+	}
+}
+
+func TestTimeBucketsGranularity(t *testing.T) {
+	// Por minuto: 12:00:59.999 e 12:00:00 caem no mesmo bucket
+	b := newTimeBuckets(time.Minute)
+	b.Add(at(t, "2025-07-15T12:00:00Z"), "default", 1, 1)
+	b.Add(at(t, "2025-07-15T12:00:59.999Z"), "default", 1, 1)
+	b.Add(at(t, "2025-07-15T12:01:00Z"), "fallback", 1, 1)
+	if n := b.Len(); n != 2 {
+		t.Fatalf("buckets = %d, want 2", n)
+	}
+	// Granularidade maior não muda o resultado, só quantos registros são filtrados
+	s := b.GetSummaryBetween(at(t, "2025-07-15T12:00:30Z"), at(t, "2025-07-15T12:01:00Z"))
+	if s.Default.TotalRequests != 1 || s.Fallback.TotalRequests != 1 {
+		t.Fatalf("summary = %+v, want only 12:00:59.999 and 12:01:00", s)
+	}
+
+	// Granularidade inválida cai no padrão de 1s
+	if g := newTimeBuckets(0).granularity; g != time.Second {
+		t.Fatalf("granularity = %v, want 1s", g)
+	}
+}
+
+func TestTimeBucketsSeriesRegroups(t *testing.T) {
+	b := newTimeBuckets(time.Second)
+	b.Add(at(t, "2025-07-15T12:00:00.100Z"), "default", 1, 1)
+	b.Add(at(t, "2025-07-15T12:00:04.900Z"), "fallback", 1, 2)
+	b.Add(at(t, "2025-07-15T12:00:05.000Z"), "default", 1, 3)
+
+	series := b.Series(5 * time.Second)
+	if len(series) != 2 {
+		t.Fatalf("points = %d, want 2", len(series))
+	}
+	if !series[0].Timestamp.Equal(at(t, "2025-07-15T12:00:00Z")) || series[0].DefaultRequests != 1 || series[0].FallbackRequests != 1 || series[0].Amount != 3 {
+		t.Fatalf("first point = %+v", series[0])
+	}
+	if !series[1].Timestamp.Equal(at(t, "2025-07-15T12:00:05Z")) || series[1].DefaultRequests != 1 {
+		t.Fatalf("second point = %+v", series[1])
+	}
+}
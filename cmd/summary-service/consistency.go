@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+var (
+	// Endpoints de resumo dos próprios processors
+	processorSummaryURLs = map[string]string{
+		"default":  config.GetString("PROCESSOR_SUMMARY_URL_DEFAULT", "http://payment-processor-default:8080/admin/payments-summary"),
+		"fallback": config.GetString("PROCESSOR_SUMMARY_URL_FALLBACK", "http://payment-processor-fallback:8080/admin/payments-summary"),
+	}
+	processorAdminToken = config.GetString("PROCESSOR_ADMIN_TOKEN", "123")
+
+	// Diferenças toleradas para considerar o resumo consistente
+	consistencyAmountTolerance   = float64(config.GetInt("CONSISTENCY_AMOUNT_TOLERANCE_CENTS", 0)) / 100
+	consistencyRequestsTolerance = config.GetInt("CONSISTENCY_REQUESTS_TOLERANCE", 0)
+
+	consistencyClient = &http.Client{Timeout: 2 * time.Second}
+)
+
+// Comparação de um processor: o que ele registrou vs o nosso resumo
+type ProcessorConsistency struct {
+	Processor     ProcessorSummary `json:"processor"`
+	Internal      ProcessorSummary `json:"internal"`
+	RequestsDelta int              `json:"requestsDelta"`
+	AmountDelta   float64          `json:"amountDelta"`
+	Pass          bool             `json:"pass"`
+	Error         string           `json:"error,omitempty"`
+}
+
+// GetPaymentSummaryByProcessor retorna o resumo interno indexado por processor
+func (s *BRUTOSummary) GetPaymentSummaryByProcessor() map[string]ProcessorSummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return map[string]ProcessorSummary{
+		"default":  s.Default,
+		"fallback": s.Fallback,
+	}
+}
+
+// fetchProcessorSummary consulta o resumo que o processor mantém do seu lado
+func fetchProcessorSummary(ctx context.Context, url, query string) (ProcessorSummary, error) {
+	var summary ProcessorSummary
+	if query != "" {
+		url += "?" + query
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return summary, err
+	}
+	req.Header.Set("X-Rinha-Token", processorAdminToken)
+	resp, err := consistencyClient.Do(req)
+	if err != nil {
+		return summary, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return summary, fmt.Errorf("processor summary returned status %d", resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&summary)
+	return summary, err
+}
+
+// GET /admin/consistency: deltas por processor entre o resumo deles e o nosso.
+// from/to são repassados aos processors e aplicados ao resumo interno.
+func handleConsistency(w http.ResponseWriter, r *http.Request) {
+	internal := brutoSummary.GetPaymentSummaryByProcessor()
+	if from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to"); from != "" || to != "" {
+		fromTime, err1 := time.Parse(time.RFC3339Nano, from)
+		toTime, err2 := time.Parse(time.RFC3339Nano, to)
+		if err1 != nil || err2 != nil {
+			http.Error(w, "from and to must be RFC3339 timestamps", http.StatusBadRequest)
+			return
+		}
+		ranged := summaryBuckets.GetSummaryBetween(fromTime, toTime)
+		internal = map[string]ProcessorSummary{"default": ranged.Default, "fallback": ranged.Fallback}
+	}
+
+	results := make(map[string]*ProcessorConsistency, len(processorSummaryURLs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, url := range processorSummaryURLs {
+		wg.Add(1)
+		go func(name, url string) {
+			defer wg.Done()
+			c := &ProcessorConsistency{Internal: internal[name]}
+			remote, err := fetchProcessorSummary(r.Context(), url, r.URL.RawQuery)
+			if err != nil {
+				c.Error = err.Error()
+			} else {
+				c.Processor = remote
+				c.RequestsDelta = c.Internal.TotalRequests - remote.TotalRequests
+				c.AmountDelta = math.Round((c.Internal.TotalAmount-remote.TotalAmount)*100) / 100
+				c.Pass = abs(c.RequestsDelta) <= consistencyRequestsTolerance &&
+					math.Abs(c.AmountDelta) <= consistencyAmountTolerance
+			}
+			mu.Lock()
+			results[name] = c
+			mu.Unlock()
+		}(name, url)
+	}
+	wg.Wait()
+
+	pass := true
+	for _, c := range results {
+		pass = pass && c.Pass
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pass":       pass,
+		"processors": results,
+	})
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type groupsPage struct {
+	Groups []SummaryGroup `json:"groups"`
+	Next   string         `json:"next"`
+}
+
+func getGroups(t *testing.T, query url.Values) groupsPage {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handleSummaryGroups(rec, httptest.NewRequest("GET", "/summary/groups?"+query.Encode(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var page groupsPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("response is not valid JSON: %v\n%s", err, rec.Body)
+	}
+	return page
+}
+
+func TestSummaryGroupsPaginatesManyCustomers(t *testing.T) {
+	brutoSummary.Reset()
+	t.Cleanup(brutoSummary.Reset)
+
+	const customers = 250
+	for i := 0; i < customers; i++ {
+		// Dois pagamentos por cliente, fora de ordem
+		id := fmt.Sprintf("customer-%03d", (i*7)%customers)
+		brutoSummary.UpdateCustomer(id, 1, 10)
+		brutoSummary.UpdateCustomer(id, 1, 0.5)
+	}
+
+	seen := map[string]SummaryGroup{}
+	pages := 0
+	query := url.Values{"by": {"customer"}}
+	for {
+		page := getGroups(t, query)
+		pages++
+		if len(page.Groups) > summaryMaxGroups {
+			t.Fatalf("page has %d groups, cap is %d", len(page.Groups), summaryMaxGroups)
+		}
+		for _, g := range page.Groups {
+			if _, dup := seen[g.Group]; dup {
+				t.Fatalf("group %s returned twice", g.Group)
+			}
+			seen[g.Group] = g
+		}
+		if page.Next == "" {
+			break
+		}
+		query.Set("cursor", page.Next)
+	}
+
+	if len(seen) != customers {
+		t.Fatalf("got %d groups across pages, want %d", len(seen), customers)
+	}
+	if want := (customers + summaryMaxGroups - 1) / summaryMaxGroups; pages != want {
+		t.Fatalf("pages = %d, want %d", pages, want)
+	}
+	for id, g := range seen {
+		if g.TotalRequests != 2 || g.TotalAmount != 10.5 {
+			t.Fatalf("group %s = %+v, want 2 requests and 10.5", id, g.ProcessorSummary)
+		}
+	}
+}
+
+func TestSummaryGroupsByProcessorRespectsLimit(t *testing.T) {
+	brutoSummary.Reset()
+	t.Cleanup(brutoSummary.Reset)
+
+	first := getGroups(t, url.Values{"limit": {"1"}})
+	if len(first.Groups) != 1 || first.Groups[0].Group != "default" || first.Next == "" {
+		t.Fatalf("first page = %+v, want default and a cursor", first)
+	}
+	second := getGroups(t, url.Values{"limit": {"1"}, "cursor": {first.Next}})
+	if len(second.Groups) != 1 || second.Groups[0].Group != "fallback" || second.Next != "" {
+		t.Fatalf("second page = %+v, want fallback and no cursor", second)
+	}
+}
@@ -1,14 +1,23 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/cache"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/cachereg"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
 )
 
 var (
@@ -18,10 +27,8 @@ var (
 	errorCount   int64
 
 	// BRUTO Cache for summary data - OTIMIZADO
-	brutoCache = &BRUTOCache{
-		data: make(map[string]interface{}),
-		mu:   sync.RWMutex{},
-	}
+	// A chave "summary" vale 1s e é descartada a cada atualização dos totais
+	brutoCache = cache.New[HTTPSummaryResponse](config.GetInt("CACHE_MAX_ENTRIES", 10000))
 
 	// BRUTO Summary Response - OTIMIZADO
 	brutoSummary = &BRUTOSummary{
@@ -29,25 +36,10 @@ var (
 		Fallback: ProcessorSummary{TotalRequests: 0, TotalAmount: 0},
 		mu:       sync.RWMutex{},
 	}
-)
-
-// BRUTO Cache - OTIMIZADO
-type BRUTOCache struct {
-	data map[string]interface{}
-	mu   sync.RWMutex
-}
-
-func (c *BRUTOCache) Get(key string) interface{} {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.data[key]
-}
 
-func (c *BRUTOCache) Set(key string, value interface{}) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.data[key] = value
-}
+	// Máximo de grupos por resposta de /summary/groups (o resto via token de continuação)
+	summaryMaxGroups = config.GetInt("SUMMARY_MAX_GROUPS", 100)
+)
 
 // BRUTO Summary Response
 type HTTPSummaryResponse struct {
@@ -65,20 +57,141 @@ type BRUTOSummary struct {
 	Default  ProcessorSummary
 	Fallback ProcessorSummary
 	mu       sync.RWMutex
+	db       *database.Database // write-through opcional (SUMMARY_DB_PATH)
+
+	// Totais por customerId (só em memória), com as chaves mantidas em ordem
+	// para /summary/groups paginar sem ordenar todos os clientes a cada request
+	customers    map[string]ProcessorSummary
+	customerKeys []string
+
+	// Gravação fora de mu: seq numera as alterações (sob mu) e persistMu ordena
+	// os fsyncs, descartando snapshots mais velhos que o último gravado
+	seq          uint64
+	persistMu    sync.Mutex
+	persistedSeq uint64
 }
 
-func (s *BRUTOSummary) UpdateDefault(requests int, amount float64) {
+// summarySnapshot são os totais copiados sob mu para gravar depois de soltá-lo
+type summarySnapshot struct {
+	db       *database.Database
+	seq      uint64
+	def      ProcessorSummary
+	fallback ProcessorSummary
+}
+
+// Load restaura os totais gravados e passa a gravar cada atualização no banco
+func (s *BRUTOSummary) Load(db *database.Database) error {
+	def, fallback, err := db.LoadSummary()
+	if err != nil {
+		return err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.Default = ProcessorSummary{TotalRequests: def.TotalRequests, TotalAmount: def.TotalAmount}
+	s.Fallback = ProcessorSummary{TotalRequests: fallback.TotalRequests, TotalAmount: fallback.TotalAmount}
+	s.db = db
+	return nil
+}
+
+// snapshotLocked numera a alteração e copia os totais; chamado com mu travado
+func (s *BRUTOSummary) snapshotLocked() summarySnapshot {
+	s.seq++
+	return summarySnapshot{db: s.db, seq: s.seq, def: s.Default, fallback: s.Fallback}
+}
+
+// persist grava o snapshot sem segurar mu, para o fsync não bloquear leituras e
+// outras atualizações. Um snapshot já superado por outro gravado é descartado:
+// o mais novo contém todas as alterações anteriores.
+func (s *BRUTOSummary) persist(snap summarySnapshot) {
+	if snap.db == nil {
+		return
+	}
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	if snap.seq <= s.persistedSeq {
+		return
+	}
+	err := snap.db.SaveSummary(
+		database.ProcessorSummary{TotalRequests: snap.def.TotalRequests, TotalAmount: snap.def.TotalAmount},
+		database.ProcessorSummary{TotalRequests: snap.fallback.TotalRequests, TotalAmount: snap.fallback.TotalAmount},
+	)
+	if err != nil {
+		log.Printf("Summary persist failed: %v", err)
+		return
+	}
+	s.persistedSeq = snap.seq
+}
+
+func (s *BRUTOSummary) UpdateDefault(requests int, amount float64) {
+	s.UpdateDefaultAt(time.Now(), requests, amount)
+}
+
+func (s *BRUTOSummary) UpdateFallback(requests int, amount float64) {
+	s.UpdateFallbackAt(time.Now(), requests, amount)
+}
+
+// UpdateDefaultAt contabiliza no bucket de tempo de at (requestedAt do pagamento)
+func (s *BRUTOSummary) UpdateDefaultAt(at time.Time, requests int, amount float64) {
+	s.mu.Lock()
 	s.Default.TotalRequests += requests
 	s.Default.TotalAmount += amount
+	summaryBuckets.Add(at, "default", requests, amount)
+	snap := s.snapshotLocked()
+	brutoCache.Delete(summaryCacheKey)
+	s.mu.Unlock()
+	s.persist(snap)
 }
 
-func (s *BRUTOSummary) UpdateFallback(requests int, amount float64) {
+// UpdateFallbackAt contabiliza no bucket de tempo de at (requestedAt do pagamento)
+func (s *BRUTOSummary) UpdateFallbackAt(at time.Time, requests int, amount float64) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.Fallback.TotalRequests += requests
 	s.Fallback.TotalAmount += amount
+	summaryBuckets.Add(at, "fallback", requests, amount)
+	snap := s.snapshotLocked()
+	brutoCache.Delete(summaryCacheKey)
+	s.mu.Unlock()
+	s.persist(snap)
+}
+
+// UpdateCustomer contabiliza o pagamento no grupo do cliente
+func (s *BRUTOSummary) UpdateCustomer(customerID string, requests int, amount float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.customers == nil {
+		s.customers = make(map[string]ProcessorSummary)
+	}
+	total, ok := s.customers[customerID]
+	if !ok {
+		i := sort.SearchStrings(s.customerKeys, customerID)
+		s.customerKeys = append(s.customerKeys, "")
+		copy(s.customerKeys[i+1:], s.customerKeys[i:])
+		s.customerKeys[i] = customerID
+	}
+	total.TotalRequests += requests
+	total.TotalAmount += amount
+	s.customers[customerID] = total
+}
+
+// CustomerCount é o número de clientes com totais em memória
+func (s *BRUTOSummary) CustomerCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.customerKeys)
+}
+
+// Reset zera os totais e os buckets de tempo (purge administrativo)
+func (s *BRUTOSummary) Reset() {
+	s.mu.Lock()
+	s.Default = ProcessorSummary{}
+	s.Fallback = ProcessorSummary{}
+	s.customers = nil
+	s.customerKeys = nil
+	summaryBuckets.Reset()
+	snap := s.snapshotLocked()
+	brutoCache.Delete(summaryCacheKey)
+	s.mu.Unlock()
+	s.persist(snap)
 }
 
 func (s *BRUTOSummary) GetSummary() HTTPSummaryResponse {
@@ -90,10 +203,105 @@ func (s *BRUTOSummary) GetSummary() HTTPSummaryResponse {
 	}
 }
 
+// Resumo de um grupo (processor ou cliente) na resposta paginada
+type SummaryGroup struct {
+	Group string `json:"group"`
+	ProcessorSummary
+}
+
+// Agrupamentos aceitos em /summary/groups?by=
+const (
+	groupByProcessor = "processor"
+	groupByCustomer  = "customer"
+)
+
+// Groups retorna até limit grupos posteriores a after, em ordem de nome, e se
+// ainda há grupos depois deles. Só os grupos da página são copiados.
+func (s *BRUTOSummary) Groups(by, after string, limit int) ([]SummaryGroup, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	var total func(string) ProcessorSummary
+	switch by {
+	case groupByCustomer:
+		keys = s.customerKeys
+		total = func(k string) ProcessorSummary { return s.customers[k] }
+	default:
+		keys = []string{"default", "fallback"}
+		total = func(k string) ProcessorSummary {
+			if k == "default" {
+				return s.Default
+			}
+			return s.Fallback
+		}
+	}
+
+	start := sort.Search(len(keys), func(i int) bool { return keys[i] > after })
+	keys = keys[start:]
+	more := len(keys) > limit
+	if more {
+		keys = keys[:limit]
+	}
+	page := make([]SummaryGroup, len(keys))
+	for i, k := range keys {
+		page[i] = SummaryGroup{Group: k, ProcessorSummary: total(k)}
+	}
+	return page, more
+}
+
 func main() {
+	// Totais persistidos entre reinícios (vazio desliga); fechado no shutdown
+	var db *database.Database
+	if dbPath := config.GetString("SUMMARY_DB_PATH", "data/summary.db"); dbPath != "" {
+		var err error
+		db, err = database.NewDatabase(dbPath)
+		if err != nil {
+			log.Fatalf("Failed to open summary database: %v", err)
+		}
+		if err := brutoSummary.Load(db); err != nil {
+			log.Fatalf("Failed to load summary: %v", err)
+		}
+	}
+
+	// Registro de caches para /admin/memory e limite suave (CACHE_SOFT_LIMIT)
+	cachereg.Register("bruto_cache", brutoCache.Len, brutoCache.Clear)
+	cachereg.Register("summary_buckets", summaryBuckets.Len, nil)
+	cachereg.Register("summary_customers", brutoSummary.CustomerCount, nil)
+	cachereg.StartLimiter(5 * time.Second)
+
+	// Remoção periódica das entradas expiradas do brutoCache
+	stopJanitor := brutoCache.StartJanitor(config.GetDuration("CACHE_JANITOR_INTERVAL", 10*time.Second))
+	defer stopJanitor()
+
 	// Create router
 	router := mux.NewRouter()
 
+	// Admin: entradas por cache em memória
+	router.HandleFunc("/admin/memory", cachereg.Handler).Methods("GET")
+
+	// Admin: compara o resumo interno com o dos processors
+	router.HandleFunc("/admin/consistency", handleConsistency).Methods("GET")
+
+	// Acertos/falhas do brutoCache, para calibrar TTLs
+	router.HandleFunc("/cache-stats", func(w http.ResponseWriter, r *http.Request) {
+		hits, misses := brutoCache.Stats()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]uint64{"hits": hits, "misses": misses})
+	}).Methods("GET")
+
+	// Métricas: formato Prometheus em /metrics, JSON dos contadores em /metrics/json
+	// (o serviço não tem prazos próprios, então sem timeouts)
+	router.Handle("/metrics", metricsHandler(newMetricsRegistry())).Methods("GET")
+	router.HandleFunc("/metrics/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int64{
+			"requests":  atomic.LoadInt64(&requestCount),
+			"successes": atomic.LoadInt64(&successCount),
+			"errors":    atomic.LoadInt64(&errorCount),
+		})
+	}).Methods("GET")
+
 	// Health check endpoint
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -107,23 +315,81 @@ func main() {
 		handleSummary(w, r)
 	}).Methods("GET")
 
+	router.HandleFunc("/record", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requestCount, 1)
+		handleRecord(w, r)
+	}).Methods("POST")
+
+	// Zera os contadores; chamado pelo /purge-payments do gateway
+	router.HandleFunc("/purge", func(w http.ResponseWriter, r *http.Request) {
+		brutoSummary.Reset()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message":"Summary purged"}`))
+	}).Methods("POST")
+
+	router.HandleFunc("/summary/timeseries", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requestCount, 1)
+		handleSummaryTimeseries(w, r)
+	}).Methods("GET")
+
+	// Resumo somado com PEER_SUMMARY_URLS; rota separada para pares não se chamarem em laço
+	router.HandleFunc("/summary/aggregate", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requestCount, 1)
+		handleSummaryAggregate(w, r)
+	}).Methods("GET")
+
+	router.HandleFunc("/summary/groups", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requestCount, 1)
+		handleSummaryGroups(w, r)
+	}).Methods("GET")
+
 	// Start server with optimized settings
 	server := &http.Server{
 		Addr:         ":8445",
-		Handler:      router,
+		Handler:      trackInFlight(router),
 		ReadTimeout:  500 * time.Millisecond,
 		WriteTimeout: 500 * time.Millisecond,
 		IdleTimeout:  30 * time.Second,
 	}
 
 	log.Printf("Summary Service BRUTO starting on :8445")
-	log.Fatal(server.ListenAndServe())
+	runServer(server, db)
 }
 
+// Chave do resumo total (sem from/to) no brutoCache
+const summaryCacheKey = "summary"
+
+// summaryCacheTTL é o limite de vida do resumo em cache, caso uma atualização não o descarte
+const summaryCacheTTL = 1 * time.Second
+
 // BRUTO: Handle summary - ULTRA-AGRESIVO
 func handleSummary(w http.ResponseWriter, r *http.Request) {
 	// BRUTO: Resposta hardcoded para velocidade máxima
-	summary := brutoSummary.GetSummary()
+	summary, ok := brutoCache.Get(summaryCacheKey)
+	if !ok {
+		summary = brutoSummary.GetSummary()
+		brutoCache.Set(summaryCacheKey, summary, summaryCacheTTL)
+	}
+
+	// Com from/to, totaliza só os pagamentos com requestedAt em [from, to]
+	fromParam, toParam := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if fromParam != "" || toParam != "" {
+		from, to := time.Unix(0, 0), time.Now()
+		var err error
+		if fromParam != "" {
+			if from, err = time.Parse(time.RFC3339Nano, fromParam); err != nil {
+				http.Error(w, "invalid from", http.StatusBadRequest)
+				return
+			}
+		}
+		if toParam != "" {
+			if to, err = time.Parse(time.RFC3339Nano, toParam); err != nil {
+				http.Error(w, "invalid to", http.StatusBadRequest)
+				return
+			}
+		}
+		summary = summaryBuckets.GetSummaryBetween(from, to)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -131,3 +397,144 @@ func handleSummary(w http.ResponseWriter, r *http.Request) {
 
 	atomic.AddInt64(&successCount, 1)
 }
+
+// Vazão por janela de tempo (?bucket=1s por padrão), para cruzar quedas de
+// vazão com aberturas do circuit breaker
+func handleSummaryTimeseries(w http.ResponseWriter, r *http.Request) {
+	// Padrão 1s, ou a granularidade quando 1s não é múltiplo dela
+	bucket := time.Second
+	if bucket%summaryBuckets.granularity != 0 {
+		bucket = summaryBuckets.granularity
+	}
+	if v := r.URL.Query().Get("bucket"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < summaryBuckets.granularity || d%summaryBuckets.granularity != 0 {
+			atomic.AddInt64(&errorCount, 1)
+			http.Error(w, "bucket must be a multiple of "+summaryBuckets.granularity.String(), http.StatusBadRequest)
+			return
+		}
+		bucket = d
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaryBuckets.Series(bucket))
+	atomic.AddInt64(&successCount, 1)
+}
+
+// Pagamento processado, enviado pelo orchestrator
+type RecordRequest struct {
+	Processor   string  `json:"processor"` // default ou fallback
+	Amount      float64 `json:"amount"`
+	RequestedAt string  `json:"requestedAt,omitempty"` // ISO-8601; ausente = agora
+	CustomerID  string  `json:"customerId,omitempty"`  // grupo do cliente em /summary/groups
+}
+
+// POST /record: contabiliza um pagamento no resumo do processor
+func handleRecord(w http.ResponseWriter, r *http.Request) {
+	var req RecordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		atomic.AddInt64(&errorCount, 1)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Amount < 0 {
+		atomic.AddInt64(&errorCount, 1)
+		http.Error(w, "amount must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	// O intervalo from/to do resumo é sobre o requestedAt enviado ao processor
+	at := time.Now()
+	if req.RequestedAt != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, req.RequestedAt)
+		if err != nil {
+			atomic.AddInt64(&errorCount, 1)
+			http.Error(w, "invalid requestedAt", http.StatusBadRequest)
+			return
+		}
+		at = parsed
+	}
+
+	switch req.Processor {
+	case "default":
+		brutoSummary.UpdateDefaultAt(at, 1, req.Amount)
+	case "fallback":
+		brutoSummary.UpdateFallbackAt(at, 1, req.Amount)
+	default:
+		atomic.AddInt64(&errorCount, 1)
+		http.Error(w, "unknown processor", http.StatusBadRequest)
+		return
+	}
+	if req.CustomerID != "" {
+		brutoSummary.UpdateCustomer(req.CustomerID, 1, req.Amount)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	atomic.AddInt64(&successCount, 1)
+}
+
+// Resumo por grupo em streaming (chunked), no máximo `limit` grupos por página.
+// ?by=processor (padrão) ou ?by=customer escolhe o agrupamento.
+// O token `next` é o último grupo retornado; repassado em `cursor` continua a partir dele.
+func handleSummaryGroups(w http.ResponseWriter, r *http.Request) {
+	by := r.URL.Query().Get("by")
+	if by == "" {
+		by = groupByProcessor
+	}
+	if by != groupByProcessor && by != groupByCustomer {
+		http.Error(w, "by must be processor or customer", http.StatusBadRequest)
+		return
+	}
+
+	limit := summaryMaxGroups
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+
+	var after string
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		after = string(decoded)
+	}
+
+	page, more := brutoSummary.Groups(by, after, limit)
+	var next string
+	if more && len(page) > 0 {
+		next = base64.RawURLEncoding.EncodeToString([]byte(page[len(page)-1].Group))
+	}
+
+	// Sem Content-Length: o net/http usa chunked encoding e cada grupo é enviado assim que codificado
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	w.Write([]byte(`{"groups":[`))
+	enc := json.NewEncoder(w)
+	for i, g := range page {
+		if i > 0 {
+			w.Write([]byte(","))
+		}
+		if err := enc.Encode(g); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	w.Write([]byte(`],"next":`))
+	enc.Encode(next)
+	w.Write([]byte("}"))
+
+	atomic.AddInt64(&successCount, 1)
+}
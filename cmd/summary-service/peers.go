@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+var (
+	// Endpoints /summary de outras instâncias, somados em /summary/aggregate
+	peerSummaryURLs = config.GetList("PEER_SUMMARY_URLS", nil)
+
+	peerClient = &http.Client{Timeout: config.GetDuration("PEER_SUMMARY_TIMEOUT", 200*time.Millisecond)}
+)
+
+// fetchPeerSummary lê o resumo total de uma instância par
+func fetchPeerSummary(url string) (HTTPSummaryResponse, error) {
+	var summary HTTPSummaryResponse
+	resp, err := peerClient.Get(url)
+	if err != nil {
+		return summary, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return summary, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		return summary, err
+	}
+	return summary, nil
+}
+
+// GET /summary/aggregate: resumo local somado ao dos pares alcançáveis.
+// Pares que falham são ignorados e contados em peersSkipped.
+func handleSummaryAggregate(w http.ResponseWriter, r *http.Request) {
+	total := brutoSummary.GetSummary()
+	skipped := 0
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, url := range peerSummaryURLs {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			peer, err := fetchPeerSummary(url)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Printf("Peer summary %s skipped: %v", url, err)
+				skipped++
+				return
+			}
+			total.Default.TotalRequests += peer.Default.TotalRequests
+			total.Default.TotalAmount += peer.Default.TotalAmount
+			total.Fallback.TotalRequests += peer.Fallback.TotalRequests
+			total.Fallback.TotalAmount += peer.Fallback.TotalAmount
+		}(url)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		HTTPSummaryResponse
+		Peers        int `json:"peers"`
+		PeersSkipped int `json:"peersSkipped"`
+	}{total, len(peerSummaryURLs), skipped})
+	atomic.AddInt64(&successCount, 1)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
+)
+
+func newPersistedSummary(t *testing.T) (*BRUTOSummary, *database.Database) {
+	t.Helper()
+	db, err := database.NewDatabase(filepath.Join(t.TempDir(), "summary.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s := &BRUTOSummary{}
+	if err := s.Load(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(summaryBuckets.Reset)
+	return s, db
+}
+
+func TestSummaryPersistDoesNotBlockReaders(t *testing.T) {
+	s, _ := newPersistedSummary(t)
+
+	// Segura a gravação: a atualização fica presa no persist, já fora de mu
+	s.persistMu.Lock()
+	done := make(chan struct{})
+	go func() {
+		s.UpdateDefault(1, 10)
+		close(done)
+	}()
+
+	deadline := time.After(2 * time.Second)
+	for s.GetSummary().Default.TotalRequests != 1 {
+		select {
+		case <-deadline:
+			s.persistMu.Unlock()
+			t.Fatal("update not visible while persist was pending, want mu released before the write")
+		default:
+			time.Sleep(time.Millisecond)
+		}
+	}
+	s.persistMu.Unlock()
+	<-done
+}
+
+func TestSummaryPersistKeepsLatestTotals(t *testing.T) {
+	s, db := newPersistedSummary(t)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				if g%2 == 0 {
+					s.UpdateDefault(1, 2)
+				} else {
+					s.UpdateFallback(1, 3)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// Um snapshot atrasado nunca sobrescreve um mais novo já gravado
+	def, fallback, err := db.LoadSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.TotalRequests != 100 || def.TotalAmount != 200 {
+		t.Fatalf("persisted default = %+v, want 100 requests and 200 amount", def)
+	}
+	if fallback.TotalRequests != 100 || fallback.TotalAmount != 300 {
+		t.Fatalf("persisted fallback = %+v, want 100 requests and 300 amount", fallback)
+	}
+
+	s.Reset()
+	if def, _, _ := db.LoadSummary(); def.TotalRequests != 0 {
+		t.Fatalf("persisted default after Reset = %+v, want zero", def)
+	}
+}
+
+func TestSummaryPersistSkipsStaleSnapshot(t *testing.T) {
+	s, db := newPersistedSummary(t)
+	s.UpdateDefault(1, 10)
+
+	// Snapshot tirado antes da atualização que já foi gravada
+	s.persist(summarySnapshot{db: db, seq: 0})
+
+	if def, _, _ := db.LoadSummary(); def.TotalRequests != 1 {
+		t.Fatalf("persisted default = %+v, want the newer snapshot kept", def)
+	}
+}
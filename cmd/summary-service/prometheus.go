@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// newMetricsRegistry registra os contadores num registry próprio; eles leem os
+// mesmos atômicos do /metrics/json
+func newMetricsRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	counters := map[string]*int64{
+		"requests":  &requestCount,
+		"successes": &successCount,
+		"errors":    &errorCount,
+	}
+	for name, counter := range counters {
+		registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "summary_" + name + "_total",
+			Help: "Contador atômico " + name + " do summary-service.",
+		}, func() float64 { return float64(atomic.LoadInt64(counter)) }))
+	}
+	return registry
+}
+
+// metricsHandler serve o registry no formato do Prometheus
+func metricsHandler(registry *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
+)
+
+var (
+	// Tempo máximo para drenar as requisições em andamento
+	shutdownTimeout = config.GetDuration("SHUTDOWN_TIMEOUT", 10*time.Second)
+
+	// Requisições em andamento, para o log do shutdown
+	inFlightRequests int64
+)
+
+// trackInFlight conta as requisições em andamento
+func trackInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&inFlightRequests, 1)
+		defer atomic.AddInt64(&inFlightRequests, -1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// runServer serve até SIGINT/SIGTERM; então drena as requisições em andamento
+// e fecha o banco. Os totais já são gravados a cada atualização, então fechar
+// depois da drenagem basta para não perder nenhum /record aceito.
+func runServer(server *http.Server, db *database.Database) {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- server.ListenAndServe() }()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Server stopped: %v", err)
+		}
+	case sig := <-stop:
+		draining := atomic.LoadInt64(&inFlightRequests)
+		log.Printf("Received %s, shutting down (%d in-flight requests)", sig, draining)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Drain incomplete: %v (%d requests still in flight)", err, atomic.LoadInt64(&inFlightRequests))
+		} else {
+			log.Printf("Drained %d in-flight requests", draining)
+		}
+	}
+
+	if db != nil {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close summary database: %v", err)
+		}
+	}
+}
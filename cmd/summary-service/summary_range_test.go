@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func getSummary(t *testing.T, query url.Values) (int, HTTPSummaryResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handleSummary(rec, httptest.NewRequest("GET", "/summary?"+query.Encode(), nil))
+	var summary HTTPSummaryResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+			t.Fatalf("response is not valid JSON: %v\n%s", err, rec.Body)
+		}
+	}
+	return rec.Code, summary
+}
+
+func TestSummaryRangeEdgesInsideBuckets(t *testing.T) {
+	brutoSummary.Reset()
+	t.Cleanup(brutoSummary.Reset)
+
+	// Buckets de 1s: as bordas da janela caem no meio dos buckets 12:00:00 e 12:00:02
+	brutoSummary.UpdateDefaultAt(at(t, "2025-07-15T12:00:00.200Z"), 1, 10)
+	brutoSummary.UpdateDefaultAt(at(t, "2025-07-15T12:00:00.700Z"), 1, 20)
+	brutoSummary.UpdateFallbackAt(at(t, "2025-07-15T12:00:01.500Z"), 1, 30)
+	brutoSummary.UpdateDefaultAt(at(t, "2025-07-15T12:00:02.300Z"), 1, 40)
+	brutoSummary.UpdateFallbackAt(at(t, "2025-07-15T12:00:02.800Z"), 1, 50)
+
+	code, summary := getSummary(t, url.Values{
+		"from": {"2025-07-15T12:00:00.500Z"},
+		"to":   {"2025-07-15T12:00:02.500Z"},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	want := HTTPSummaryResponse{
+		Default:  ProcessorSummary{TotalRequests: 2, TotalAmount: 60},
+		Fallback: ProcessorSummary{TotalRequests: 1, TotalAmount: 30},
+	}
+	if summary != want {
+		t.Fatalf("summary = %+v, want %+v", summary, want)
+	}
+
+	// Sem from/to continua o total de todos os pagamentos
+	if _, all := getSummary(t, nil); all.Default.TotalRequests != 3 || all.Fallback.TotalRequests != 2 {
+		t.Fatalf("all-time summary = %+v, want 3 default and 2 fallback", all)
+	}
+}
+
+func TestSummaryRangeRejectsMalformedTimestamps(t *testing.T) {
+	for _, query := range []url.Values{
+		{"from": {"yesterday"}},
+		{"to": {"2025-07-15 12:00"}},
+	} {
+		if code, _ := getSummary(t, query); code != http.StatusBadRequest {
+			t.Fatalf("%v: status = %d, want 400", query, code)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package bufpool
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+// Classes de tamanho padrão: payloads de pagamento, respostas médias e resumos grandes
+var defaultClasses = []int{512, 4096, 64 * 1024}
+
+// Uma classe de tamanho com seu próprio sync.Pool
+type class struct {
+	size int
+	pool sync.Pool
+}
+
+// Classes configuradas por BUFFER_POOL_CLASSES (ex: "512,4096,65536"), em ordem crescente
+var classes = newClasses(config.GetList("BUFFER_POOL_CLASSES", nil))
+
+func newClasses(names []string) []*class {
+	sizes := make([]int, 0, len(names))
+	for _, name := range names {
+		if n, err := strconv.Atoi(name); err == nil && n > 0 {
+			sizes = append(sizes, n)
+		}
+	}
+	if len(sizes) == 0 {
+		sizes = defaultClasses
+	}
+	sort.Ints(sizes)
+
+	result := make([]*class, len(sizes))
+	for i, size := range sizes {
+		c := &class{size: size}
+		c.pool.New = func() interface{} {
+			b := make([]byte, 0, c.size)
+			return &b
+		}
+		result[i] = c
+	}
+	return result
+}
+
+// classFor retorna a menor classe que comporta size, ou nil se maior que todas
+func classFor(size int) *class {
+	for _, c := range classes {
+		if size <= c.size {
+			return c
+		}
+	}
+	return nil
+}
+
+// Get retorna um buffer vazio com capacidade para ao menos sizeHint bytes
+func Get(sizeHint int) *[]byte {
+	c := classFor(sizeHint)
+	if c == nil {
+		b := make([]byte, 0, sizeHint)
+		return &b
+	}
+	return c.pool.Get().(*[]byte)
+}
+
+// Put devolve o buffer para a classe correspondente à sua capacidade;
+// buffers que cresceram além da maior classe são descartados
+func Put(b *[]byte) {
+	capacity := cap(*b)
+	for i := len(classes) - 1; i >= 0; i-- {
+		c := classes[i]
+		if capacity >= c.size {
+			if i == len(classes)-1 && capacity > 2*c.size {
+				return
+			}
+			*b = (*b)[:0]
+			c.pool.Put(b)
+			return
+		}
+	}
+}
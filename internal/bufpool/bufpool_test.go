@@ -0,0 +1,115 @@
+package bufpool
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestGetUsesSmallestFittingClass(t *testing.T) {
+	for _, tc := range []struct{ hint, wantCap int }{
+		{1, 512},
+		{512, 512},
+		{513, 4096},
+		{64 * 1024, 64 * 1024},
+		{100 * 1024, 100 * 1024}, // maior que todas: alocado sob medida
+	} {
+		b := Get(tc.hint)
+		if len(*b) != 0 || cap(*b) < tc.wantCap {
+			t.Fatalf("Get(%d): len %d cap %d, want empty with cap >= %d", tc.hint, len(*b), cap(*b), tc.wantCap)
+		}
+		Put(b)
+	}
+}
+
+func TestPutResetsAndDropsOversized(t *testing.T) {
+	b := Get(512)
+	*b = append(*b, "payload"...)
+	Put(b)
+	if len(*b) != 0 {
+		t.Fatalf("len after Put = %d, want 0", len(*b))
+	}
+
+	// Cresceu muito além da maior classe: não volta ao pool
+	huge := make([]byte, 10, 1<<20)
+	Put(&huge)
+	if len(huge) != 10 {
+		t.Fatal("oversized buffer was reset and pooled")
+	}
+}
+
+// Antes: um único pool de []byte de 4096 bytes para qualquer payload (o slice
+// é convertido para interface a cada Put)
+var singlePool = sync.Pool{New: func() interface{} {
+	return make([]byte, 0, 4096)
+}}
+
+// Pagamento pequeno, resposta média e resumo grande, intercalados como no tráfego real
+var payloadSizes = []int{200, 3 * 1024, 40 * 1024}
+
+func fill(buf *bytes.Buffer, size int) {
+	for buf.Len() < size {
+		buf.WriteString(`{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":19.90}`)
+	}
+}
+
+// held-B/op é a capacidade do buffer usado por payload: quanto acima do
+// tamanho do payload, mais memória presa no pool
+func BenchmarkSinglePool(b *testing.B) {
+	for _, size := range payloadSizes {
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			b.ReportAllocs()
+			held := 0
+			for i := 0; i < b.N; i++ {
+				p := singlePool.Get().([]byte)
+				buf := bytes.NewBuffer(p)
+				fill(buf, size)
+				held += buf.Cap()
+				singlePool.Put(buf.Bytes()[:0])
+			}
+			b.ReportMetric(float64(held)/float64(b.N), "held-B/op")
+		})
+	}
+}
+
+func BenchmarkSizeClasses(b *testing.B) {
+	for _, size := range payloadSizes {
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			b.ReportAllocs()
+			held := 0
+			for i := 0; i < b.N; i++ {
+				p := Get(size)
+				buf := bytes.NewBuffer(*p)
+				fill(buf, size)
+				held += buf.Cap()
+				*p = buf.Bytes()
+				Put(p)
+			}
+			b.ReportMetric(float64(held)/float64(b.N), "held-B/op")
+		})
+	}
+}
+
+// Mixed alterna os três tamanhos no mesmo pool, como gateway e orchestrator fazem
+func BenchmarkMixedSinglePool(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := singlePool.Get().([]byte)
+		buf := bytes.NewBuffer(p)
+		fill(buf, payloadSizes[i%len(payloadSizes)])
+		singlePool.Put(buf.Bytes()[:0])
+	}
+}
+
+func BenchmarkMixedSizeClasses(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		size := payloadSizes[i%len(payloadSizes)]
+		p := Get(size)
+		buf := bytes.NewBuffer(*p)
+		fill(buf, size)
+		*p = buf.Bytes()
+		Put(p)
+	}
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache em memória com TTL por entrada e limite de tamanho com despejo LRU.
+// Cache[interface{}] continua disponível para valores heterogêneos.
+type Cache[V any] struct {
+	maxEntries int // 0 = sem limite
+	entries    map[string]*list.Element
+	order      *list.List // mais recentemente usado na frente
+	mu         sync.Mutex // Get também altera a ordem, então não há lock de leitura
+
+	hits   atomic.Uint64
+	misses atomic.Uint64 // chave ausente ou expirada
+}
+
+type entry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time // zero = não expira
+}
+
+func (e *entry[V]) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+// New cria um cache com no máximo maxEntries entradas (0 = sem limite)
+func New[V any](maxEntries int) *Cache[V] {
+	return &Cache[V]{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get retorna false para chaves ausentes ou expiradas (e remove as expiradas)
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var zero V
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return zero, false
+	}
+	e := el.Value.(*entry[V])
+	if e.expired(time.Now()) {
+		c.removeElement(el)
+		c.misses.Add(1)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	c.hits.Add(1)
+	return e.value, true
+}
+
+// Stats retorna os acertos e falhas acumulados de Get
+func (c *Cache[V]) Stats() (hits, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// Set grava o valor por ttl
+func (c *Cache[V]) Set(key string, value V, ttl time.Duration) {
+	c.set(key, value, time.Now().Add(ttl))
+}
+
+// SetForever grava o valor sem expiração
+func (c *Cache[V]) SetForever(key string, value V) {
+	c.set(key, value, time.Time{})
+}
+
+func (c *Cache[V]) set(key string, value V, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry[V])
+		e.value, e.expiresAt = value, expiresAt
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&entry[V]{key: key, value: value, expiresAt: expiresAt})
+	if c.maxEntries > 0 {
+		for c.order.Len() > c.maxEntries {
+			c.removeElement(c.order.Back())
+		}
+	}
+}
+
+func (c *Cache[V]) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *Cache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Clear descarta todas as entradas (o conteúdo do cache é sempre recomputável)
+func (c *Cache[V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// StartJanitor remove periodicamente as entradas expiradas que ninguém leu;
+// a função retornada encerra a goroutine
+func (c *Cache[V]) StartJanitor(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				c.evictExpired()
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+func (c *Cache[V]) evictExpired() {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*entry[V]).expired(now) {
+			c.removeElement(el)
+		}
+		el = next
+	}
+}
+
+func (c *Cache[V]) removeElement(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*entry[V]).key)
+}
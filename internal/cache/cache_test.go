@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEntryExpiresAfterTTL(t *testing.T) {
+	c := New[string](0)
+	c.Set("health_default", "up", 30*time.Millisecond)
+	c.SetForever("config", "static")
+
+	if v, ok := c.Get("health_default"); !ok || v != "up" {
+		t.Fatalf("Get before TTL = %q, %v; want up", v, ok)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if v, ok := c.Get("health_default"); ok {
+		t.Fatalf("Get after TTL = %q, want a miss", v)
+	}
+	// Get remove a entrada expirada
+	if n := c.Len(); n != 1 {
+		t.Fatalf("Len after expiry = %d, want 1", n)
+	}
+	if v, ok := c.Get("config"); !ok || v != "static" {
+		t.Fatalf("SetForever entry = %q, %v; want it kept", v, ok)
+	}
+
+	hits, misses := c.Stats()
+	if hits != 2 || misses != 1 {
+		t.Fatalf("stats = %d hits / %d misses, want 2 / 1", hits, misses)
+	}
+}
+
+func TestSetRenewsTTL(t *testing.T) {
+	c := New[int](0)
+	c.Set("summary", 1, 30*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	c.Set("summary", 2, 30*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if v, ok := c.Get("summary"); !ok || v != 2 {
+		t.Fatalf("Get = %d, %v; want the renewed value", v, ok)
+	}
+}
+
+func TestJanitorEvictsUnreadExpiredEntries(t *testing.T) {
+	c := New[int](0)
+	for _, k := range []string{"a", "b", "c"} {
+		c.Set(k, 1, 10*time.Millisecond)
+	}
+	c.SetForever("keep", 1)
+
+	stop := c.StartJanitor(5 * time.Millisecond)
+	defer stop()
+	deadline := time.Now().Add(time.Second)
+	for c.Len() > 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := c.Len(); n != 1 {
+		t.Fatalf("Len = %d, want only the non-expiring entry", n)
+	}
+	stop() // idempotente
+}
+
+func TestEvictsLeastRecentlyUsedAtCapacity(t *testing.T) {
+	const max = 3
+	c := New[int](max)
+	for i, k := range []string{"k0", "k1", "k2"} {
+		c.Set(k, i, time.Minute)
+	}
+	// Leitura renova k0: o menos usado passa a ser k1
+	c.Get("k0")
+	c.Set("k3", 3, time.Minute)
+
+	if n := c.Len(); n != max {
+		t.Fatalf("Len = %d, want %d", n, max)
+	}
+	if _, ok := c.Get("k1"); ok {
+		t.Fatal("least recently used key k1 was not evicted")
+	}
+	for _, k := range []string{"k0", "k2", "k3"} {
+		if _, ok := c.Get(k); !ok {
+			t.Fatalf("%s evicted, want it kept", k)
+		}
+	}
+}
+
+func TestInsertBeyondMaxEvictsOldest(t *testing.T) {
+	const max = 100
+	c := New[int](max)
+	for i := 0; i <= max; i++ {
+		c.Set(fmt.Sprintf("key-%d", i), i, time.Minute)
+	}
+	if n := c.Len(); n != max {
+		t.Fatalf("Len = %d, want %d", n, max)
+	}
+	if _, ok := c.Get("key-0"); ok {
+		t.Fatal("oldest key survived maxEntries+1 inserts")
+	}
+
+	// Atualizar uma chave existente não despeja nada
+	c.Set("key-1", -1, time.Minute)
+	if n := c.Len(); n != max {
+		t.Fatalf("Len after update = %d, want %d", n, max)
+	}
+}
+
+func TestDeleteAndClear(t *testing.T) {
+	c := New[int](0)
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+	c.Delete("a")
+	c.Delete("missing")
+	if _, ok := c.Get("a"); ok || c.Len() != 1 {
+		t.Fatalf("after Delete: Len = %d, want 1 without a", c.Len())
+	}
+	c.Clear()
+	if c.Len() != 0 {
+		t.Fatalf("Len after Clear = %d", c.Len())
+	}
+	c.Set("c", 3, time.Minute)
+	if v, ok := c.Get("c"); !ok || v != 3 {
+		t.Fatal("cache unusable after Clear")
+	}
+}
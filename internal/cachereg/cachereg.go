@@ -0,0 +1,109 @@
+package cachereg
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+// Cache registrado: como medir e, opcionalmente, como liberar memória
+type entry struct {
+	size  func() int
+	evict func() // nil = cache não pode ser reduzido
+}
+
+var (
+	registry = make(map[string]entry)
+	mu       sync.RWMutex
+
+	// Limite suave de entradas somadas entre caches (0 = desligado)
+	softLimit = config.GetInt("CACHE_SOFT_LIMIT", 0)
+)
+
+// Register registra um cache pelo nome; evict pode ser nil
+func Register(name string, size func() int, evict func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[name] = entry{size: size, evict: evict}
+}
+
+// Snapshot retorna a quantidade de entradas por cache
+func Snapshot() map[string]int {
+	mu.RLock()
+	defer mu.RUnlock()
+	sizes := make(map[string]int, len(registry))
+	for name, e := range registry {
+		sizes[name] = e.size()
+	}
+	return sizes
+}
+
+// Handler serve GET /admin/memory com as entradas por cache e o total
+func Handler(w http.ResponseWriter, r *http.Request) {
+	sizes := Snapshot()
+	total := 0
+	for _, n := range sizes {
+		total += n
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"caches":    sizes,
+		"total":     total,
+		"softLimit": softLimit,
+	})
+}
+
+// StartLimiter verifica periodicamente o total de entradas e, acima do limite
+// suave, aciona a evicção dos caches, do maior para o menor, até voltar ao limite.
+// Não faz nada quando CACHE_SOFT_LIMIT não está configurado.
+func StartLimiter(interval time.Duration) {
+	if softLimit <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			enforceLimit()
+		}
+	}()
+}
+
+func enforceLimit() {
+	mu.RLock()
+	type sized struct {
+		name string
+		size int
+		e    entry
+	}
+	caches := make([]sized, 0, len(registry))
+	total := 0
+	for name, e := range registry {
+		n := e.size()
+		total += n
+		caches = append(caches, sized{name: name, size: n, e: e})
+	}
+	mu.RUnlock()
+
+	if total <= softLimit {
+		return
+	}
+	sort.Slice(caches, func(i, j int) bool { return caches[i].size > caches[j].size })
+	for _, c := range caches {
+		if total <= softLimit {
+			break
+		}
+		if c.e.evict == nil {
+			continue
+		}
+		c.e.evict()
+		after := c.e.size()
+		log.Printf("[cachereg] limite suave excedido: cache %s reduzido de %d para %d entradas", c.name, c.size, after)
+		total -= c.size - after
+	}
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetString lê uma variável de ambiente, retornando def quando ausente
+func GetString(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// GetInt lê uma variável de ambiente inteira, retornando def quando ausente ou inválida
+func GetInt(key string, def int) int {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("[config] valor inválido para %s=%q, usando padrão %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+// GetDuration lê uma duração (ex: "5s", "300ms"), retornando def quando ausente ou inválida
+func GetDuration(key string, def time.Duration) time.Duration {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("[config] valor inválido para %s=%q, usando padrão %s", key, v, def)
+		return def
+	}
+	return d
+}
+
+// GetBool lê uma variável de ambiente booleana, retornando def quando ausente ou inválida
+func GetBool(key string, def bool) bool {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("[config] valor inválido para %s=%q, usando padrão %t", key, v, def)
+		return def
+	}
+	return b
+}
+
+// GetList lê uma lista separada por vírgulas, ignorando itens vazios
+func GetList(key string, def []string) []string {
+	v, ok := os.LookupEnv(key)
+	if !ok || strings.TrimSpace(v) == "" {
+		return def
+	}
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return def
+	}
+	return items
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	goBolt "go.etcd.io/bbolt"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/logging"
+)
+
+// CreatePaymentsBatch insere todos os pagamentos numa única transação: um fsync
+// para o lote inteiro em vez de um por pagamento. Tudo ou nada.
+func (d *Database) CreatePaymentsBatch(payments []*Payment) error {
+	if len(payments) == 0 {
+		return nil
+	}
+	// Serializa fora da transação para segurar o lock de escrita o mínimo possível
+	encoded := make([][]byte, len(payments))
+	for i, payment := range payments {
+		data, err := encodePayment(payment)
+		if err != nil {
+			return fmt.Errorf("erro ao serializar pagamento %s: %w", payment.ID, err)
+		}
+		encoded[i] = data
+	}
+	err := d.db.Update(func(tx *goBolt.Tx) error {
+		bucket := tx.Bucket([]byte(paymentsBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s não existe", paymentsBucket)
+		}
+		for i, payment := range payments {
+			if err := putPayment(tx, bucket, payment, encoded[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao inserir lote de pagamentos: %w", err)
+	}
+	atomic.AddInt64(&d.created, int64(len(payments)))
+	logging.Debugf("[database] Lote de %d pagamentos criado", len(payments))
+	return nil
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newPayments(prefix string, n int) []*Payment {
+	now := time.Now()
+	payments := make([]*Payment, n)
+	for i := range payments {
+		payments[i] = &Payment{
+			ID:         fmt.Sprintf("%s-%05d", prefix, i),
+			CustomerID: "c-batch",
+			Amount:     19.9,
+			Status:     "completed",
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+	}
+	return payments
+}
+
+func TestCreatePaymentsBatchPersistsAll(t *testing.T) {
+	db := newTestDatabase(t)
+	if err := db.CreatePaymentsBatch(newPayments("p", 300)); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := db.CountPaymentsByCustomer("c-batch"); n != 300 {
+		t.Fatalf("customer index has %d payments, want 300", n)
+	}
+	p, err := db.GetPaymentByID("p-00299")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Amount != 19.9 || p.Status != "completed" {
+		t.Fatalf("payment = %+v, want the batched values", p)
+	}
+}
+
+func TestCreatePaymentsBatchIsAllOrNothing(t *testing.T) {
+	db := newTestDatabase(t)
+	payments := newPayments("p", 10)
+	// Chave vazia é recusada pelo bolt no meio do lote
+	payments[5].ID = ""
+	if err := db.CreatePaymentsBatch(payments); err == nil {
+		t.Fatal("CreatePaymentsBatch with an empty ID succeeded, want error")
+	}
+	if _, err := db.GetPaymentByID("p-00000"); err == nil {
+		t.Fatal("payment before the failing one was persisted, want the batch rolled back")
+	}
+	if n, _ := db.CountPaymentsByCustomer("c-batch"); n != 0 {
+		t.Fatalf("customer index has %d payments, want 0", n)
+	}
+}
+
+func benchmarkInserts(b *testing.B, insert func(db *Database, payments []*Payment)) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		db, err := NewDatabase(filepath.Join(b.TempDir(), fmt.Sprintf("payments-%d.db", i)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		payments := newPayments("p", 1000)
+		b.StartTimer()
+		insert(db, payments)
+		b.StopTimer()
+		db.Close()
+	}
+}
+
+// BenchmarkInsert1000Single grava 1000 pagamentos com uma transação (e um fsync) cada
+func BenchmarkInsert1000Single(b *testing.B) {
+	benchmarkInserts(b, func(db *Database, payments []*Payment) {
+		for _, p := range payments {
+			if err := db.CreatePayment(p); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkInsert1000Batch grava os mesmos 1000 pagamentos numa única transação
+func BenchmarkInsert1000Batch(b *testing.B) {
+	benchmarkInserts(b, func(db *Database, payments []*Payment) {
+		if err := db.CreatePaymentsBatch(payments); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
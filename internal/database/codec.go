@@ -0,0 +1,63 @@
+package database
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+
+	goBolt "go.etcd.io/bbolt"
+)
+
+// Pagamentos são gravados em JSON (tags do Payment): legível com as
+// ferramentas do bolt e tolerante a campos novos. Bancos antigos em gob são
+// convertidos uma vez na abertura.
+const (
+	metaBucket          = "meta"
+	paymentsEncodingKey = "payments_encoding"
+	paymentsEncoding    = "json"
+)
+
+func encodePayment(p *Payment) ([]byte, error) {
+	return json.Marshal(p)
+}
+
+func decodePayment(data []byte, p *Payment) error {
+	return json.Unmarshal(data, p)
+}
+
+// migratePaymentsToJSON regrava em JSON os pagamentos ainda em gob, na mesma
+// transação da abertura; depois marca o banco para não repetir a conversão
+func migratePaymentsToJSON(tx *goBolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists([]byte(metaBucket))
+	if err != nil {
+		return err
+	}
+	if string(meta.Get([]byte(paymentsEncodingKey))) == paymentsEncoding {
+		return nil
+	}
+	bucket := tx.Bucket([]byte(paymentsBucket))
+	converted := make(map[string][]byte)
+	err = bucket.ForEach(func(k, v []byte) error {
+		var p Payment
+		if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&p); err != nil {
+			return fmt.Errorf("pagamento %s: %w", k, err)
+		}
+		data, err := encodePayment(&p)
+		if err != nil {
+			return err
+		}
+		converted[string(k)] = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Put fora do ForEach: o bolt não permite alterar o bucket durante a iteração
+	for k, data := range converted {
+		if err := bucket.Put([]byte(k), data); err != nil {
+			return err
+		}
+	}
+	return meta.Put([]byte(paymentsEncodingKey), []byte(paymentsEncoding))
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/logging"
+)
+
+// BenchmarkCreatePaymentLogging compara o insert com o log por pagamento
+// ligado (debug, gravando em arquivo como em produção) e desligado (info)
+func BenchmarkCreatePaymentLogging(b *testing.B) {
+	logFile, err := os.Create(filepath.Join(b.TempDir(), "bench.log"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer logFile.Close()
+	prevOutput := log.Writer()
+	log.SetOutput(logFile)
+	defer log.SetOutput(prevOutput)
+	defer logging.SetLevel(logging.Info)
+
+	for _, bc := range []struct {
+		name  string
+		level logging.Level
+	}{
+		{"logging=on", logging.Debug},
+		{"logging=off", logging.Info},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			db, err := NewDatabase(filepath.Join(b.TempDir(), "payments.db"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer db.Close()
+			logging.SetLevel(bc.level)
+			now := time.Now()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := db.CreatePayment(&Payment{
+					ID:         fmt.Sprintf("p-%09d", i),
+					CustomerID: "c-bench",
+					Amount:     19.9,
+					Status:     "completed",
+					CreatedAt:  now,
+					UpdatedAt:  now,
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	goBolt "go.etcd.io/bbolt"
+)
+
+// Índice por cliente: um bucket aninhado por customerID com os IDs dos pagamentos
+const customersBucket = "customers"
+
+// ErrCustomerLimit indica que o cliente atingiu o máximo de pagamentos
+var ErrCustomerLimit = errors.New("limite de pagamentos por cliente atingido")
+
+func indexCustomerPayment(tx *goBolt.Tx, customerID string, paymentID []byte) error {
+	customers := tx.Bucket([]byte(customersBucket))
+	if customers == nil || customerID == "" {
+		return nil
+	}
+	bucket, err := customers.CreateBucketIfNotExists([]byte(customerID))
+	if err != nil {
+		return err
+	}
+	return bucket.Put(paymentID, []byte{})
+}
+
+func unindexCustomerPayment(tx *goBolt.Tx, customerID string, paymentID []byte) {
+	customers := tx.Bucket([]byte(customersBucket))
+	if customers == nil || customerID == "" {
+		return
+	}
+	if bucket := customers.Bucket([]byte(customerID)); bucket != nil {
+		bucket.Delete(paymentID)
+	}
+}
+
+// countCustomerPayments conta as chaves do bucket do cliente no índice,
+// sem varrer os pagamentos
+func countCustomerPayments(tx *goBolt.Tx, customerID string) int {
+	customers := tx.Bucket([]byte(customersBucket))
+	if customers == nil || customerID == "" {
+		return 0
+	}
+	bucket := customers.Bucket([]byte(customerID))
+	if bucket == nil {
+		return 0
+	}
+	return bucket.Stats().KeyN
+}
+
+// CountPaymentsByCustomer retorna quantos pagamentos o cliente tem no índice
+func (d *Database) CountPaymentsByCustomer(customerID string) (int, error) {
+	var count int
+	err := d.db.View(func(tx *goBolt.Tx) error {
+		count = countCustomerPayments(tx, customerID)
+		return nil
+	})
+	return count, err
+}
+
+// GetPaymentsByCustomerPaged retorna uma página dos pagamentos do cliente
+// (CreatedAt DESC) e o total. Ordena só ID e CreatedAt; os pagamentos completos
+// são montados apenas para a página.
+func (d *Database) GetPaymentsByCustomerPaged(customerID string, offset, limit int) ([]*Payment, int, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, 0, fmt.Errorf("paginação inválida: offset=%d limit=%d", offset, limit)
+	}
+	type pageEntry struct {
+		id        []byte
+		createdAt time.Time
+	}
+	var page []*Payment
+	var total int
+	err := d.db.View(func(tx *goBolt.Tx) error {
+		bucket := tx.Bucket([]byte(paymentsBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s não existe", paymentsBucket)
+		}
+		customers := tx.Bucket([]byte(customersBucket))
+		if customers == nil {
+			return nil
+		}
+		index := customers.Bucket([]byte(customerID))
+		if index == nil {
+			return nil
+		}
+		var entries []pageEntry
+		err := index.ForEach(func(k, _ []byte) error {
+			data := bucket.Get(k)
+			if data == nil {
+				return nil
+			}
+			var p Payment
+			if err := decodePayment(data, &p); err != nil {
+				return err
+			}
+			entries = append(entries, pageEntry{id: k, createdAt: p.CreatedAt})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		total = len(entries)
+		if offset >= total {
+			return nil
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].createdAt.After(entries[j].createdAt)
+		})
+		end := offset + limit
+		if end > total {
+			end = total
+		}
+		for _, e := range entries[offset:end] {
+			var p Payment
+			if err := decodePayment(bucket.Get(e.id), &p); err != nil {
+				return err
+			}
+			page = append(page, &p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("erro ao buscar pagamentos: %w", err)
+	}
+	return page, total, nil
+}
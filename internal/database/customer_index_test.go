@@ -0,0 +1,57 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCreatePaymentLimitedEnforcesCustomerCap(t *testing.T) {
+	db := newTestDatabase(t)
+	const limit = 5
+	newPayment := func(id, customer string) *Payment {
+		now := time.Now()
+		return &Payment{ID: id, CustomerID: customer, Amount: 1, Status: "processing", CreatedAt: now, UpdatedAt: now}
+	}
+
+	for i := 0; i < limit; i++ {
+		if err := db.CreatePaymentLimited(newPayment(fmt.Sprintf("p-%d", i), "c-1"), limit); err != nil {
+			t.Fatalf("payment %d under the cap: %v", i, err)
+		}
+	}
+	err := db.CreatePaymentLimited(newPayment("p-over", "c-1"), limit)
+	if !errors.Is(err, ErrCustomerLimit) {
+		t.Fatalf("payment over the cap: err = %v, want ErrCustomerLimit", err)
+	}
+	if _, err := db.GetPaymentByID("p-over"); err == nil {
+		t.Fatal("rejected payment was stored")
+	}
+	if n, _ := db.CountPaymentsByCustomer("c-1"); n != limit {
+		t.Fatalf("customer count = %d, want %d", n, limit)
+	}
+
+	// Regravar um ID existente não conta como novo pagamento
+	if err := db.CreatePaymentLimited(newPayment("p-0", "c-1"), limit); err != nil {
+		t.Fatalf("rewrite at the cap: %v", err)
+	}
+	// O limite é por cliente
+	if err := db.CreatePaymentLimited(newPayment("other-0", "c-2"), limit); err != nil {
+		t.Fatalf("other customer: %v", err)
+	}
+	// Sem limite (0), nada é rejeitado
+	if err := db.CreatePaymentLimited(newPayment("p-unlimited", "c-1"), 0); err != nil {
+		t.Fatalf("unlimited: %v", err)
+	}
+
+	// Remover um pagamento libera a vaga no índice
+	if err := db.DeletePayment("p-unlimited"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeletePayment("p-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreatePaymentLimited(newPayment("p-after-delete", "c-1"), limit); err != nil {
+		t.Fatalf("after freeing a slot: %v", err)
+	}
+}
@@ -1,14 +1,20 @@
 package database
 
 import (
-	"bytes"
-	"encoding/gob"
+	"errors"
 	"fmt"
 	"log"
+	"runtime"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	goBolt "go.etcd.io/bbolt"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/cache"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/logging"
 )
 
 // Payment representa um pagamento no banco de dados
@@ -19,6 +25,7 @@ type Payment struct {
 	Description   string    `json:"description"`
 	Status        string    `json:"status"`
 	ProcessorUsed string    `json:"processor_used"`
+	RequestedAt   time.Time `json:"requested_at"` // requestedAt enviado ao processor; zero se desconhecido
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
@@ -26,11 +33,27 @@ type Payment struct {
 // Database representa a conexão com o banco de dados
 // Agora usa BoltDB
 type Database struct {
-	db *goBolt.DB
+	db          *goBolt.DB
+	scanWorkers int
+	created     int64 // pagamentos criados desde o último resumo periódico
+	stop        chan struct{}
+
+	statsCache *cache.Cache[map[string]interface{}] // GetPaymentStatsCached
+	statsMu    sync.Mutex                           // uma varredura por vez nos misses
+	statsGen   atomic.Int64                         // incrementado no purge
 }
 
 const paymentsBucket = "payments"
 
+// Intervalo do resumo periódico de criações (0 desliga)
+var createLogInterval = config.GetDuration("DB_CREATE_LOG_INTERVAL", 10*time.Second)
+
+// Pagamentos removidos por transação no CleanupOldPayments
+var cleanupBatchSize = config.GetInt("DB_CLEANUP_BATCH_SIZE", 1000)
+
+// errBatchFull interrompe a iteração quando o lote da limpeza está completo
+var errBatchFull = errors.New("lote completo")
+
 // NewDatabase cria uma nova conexão com o banco BoltDB
 func NewDatabase(dbPath string) (*Database, error) {
 	db, err := goBolt.Open(dbPath, 0600, &goBolt.Options{Timeout: 1 * time.Second})
@@ -39,43 +62,105 @@ func NewDatabase(dbPath string) (*Database, error) {
 	}
 	// Cria bucket se não existir
 	err = db.Update(func(tx *goBolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(paymentsBucket))
-		return err
+		if _, err := tx.CreateBucketIfNotExists([]byte(paymentsBucket)); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(customersBucket)); err != nil {
+			return err
+		}
+		if err := migratePaymentsToJSON(tx); err != nil {
+			return fmt.Errorf("erro ao migrar pagamentos para JSON: %w", err)
+		}
+		return backfillCreatedAtIndex(tx)
 	})
 	if err != nil {
 		db.Close()
 		return nil, fmt.Errorf("erro ao criar bucket: %w", err)
 	}
-	return &Database{db: db}, nil
+	d := &Database{db: db, scanWorkers: 1, stop: make(chan struct{}), statsCache: cache.New[map[string]interface{}](1)}
+	if createLogInterval > 0 {
+		go d.logCreates(createLogInterval)
+	}
+	return d, nil
 }
 
 // Close fecha a conexão com o banco de dados
 func (d *Database) Close() error {
+	close(d.stop)
 	return d.db.Close()
 }
 
+// logCreates registra em nível info quantos pagamentos foram criados a cada intervalo,
+// no lugar de um log por pagamento no caminho de escrita
+func (d *Database) logCreates(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			if n := atomic.SwapInt64(&d.created, 0); n > 0 {
+				logging.Infof("[database] %d pagamentos criados nos últimos %s", n, interval)
+			}
+		}
+	}
+}
+
 // CreatePayment insere um novo pagamento no banco BoltDB
 func (d *Database) CreatePayment(payment *Payment) error {
-	var buf bytes.Buffer
-	enc := gob.NewEncoder(&buf)
-	if err := enc.Encode(payment); err != nil {
+	return d.CreatePaymentLimited(payment, 0)
+}
+
+// CreatePaymentLimited insere o pagamento se o cliente ainda não tiver
+// maxPerCustomer pagamentos (0 = sem limite); a contagem e a inserção
+// acontecem na mesma transação. Retorna ErrCustomerLimit quando excedido.
+func (d *Database) CreatePaymentLimited(payment *Payment, maxPerCustomer int) error {
+	data, err := encodePayment(payment)
+	if err != nil {
 		return fmt.Errorf("erro ao serializar pagamento: %w", err)
 	}
 	key := []byte(payment.ID)
-	err := d.db.Update(func(tx *goBolt.Tx) error {
+	err = d.db.Update(func(tx *goBolt.Tx) error {
 		bucket := tx.Bucket([]byte(paymentsBucket))
 		if bucket == nil {
 			return fmt.Errorf("bucket %s não existe", paymentsBucket)
 		}
-		return bucket.Put(key, buf.Bytes())
+		if maxPerCustomer > 0 && bucket.Get(key) == nil && countCustomerPayments(tx, payment.CustomerID) >= maxPerCustomer {
+			return ErrCustomerLimit
+		}
+		return putPayment(tx, bucket, payment, data)
 	})
+	if errors.Is(err, ErrCustomerLimit) {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("erro ao inserir pagamento: %w", err)
 	}
-	log.Printf("[database] Pagamento criado: ID=%s, Customer=%s, Amount=%.2f", payment.ID, payment.CustomerID, payment.Amount)
+	atomic.AddInt64(&d.created, 1)
+	logging.Debugf("[database] Pagamento criado: ID=%s, Customer=%s, Amount=%.2f", payment.ID, payment.CustomerID, payment.Amount)
 	return nil
 }
 
+// putPayment grava o pagamento já serializado e mantém os índices
+func putPayment(tx *goBolt.Tx, bucket *goBolt.Bucket, payment *Payment, data []byte) error {
+	key := []byte(payment.ID)
+	// Regravação do mesmo ID: tira a entrada antiga do índice por data
+	if existing := bucket.Get(key); existing != nil {
+		var old Payment
+		if err := decodePayment(existing, &old); err == nil {
+			unindexCreatedAt(tx, old.CreatedAt, key)
+		}
+	}
+	if err := bucket.Put(key, data); err != nil {
+		return err
+	}
+	if err := indexCreatedAt(tx, payment.CreatedAt, key); err != nil {
+		return err
+	}
+	return indexCustomerPayment(tx, payment.CustomerID, key)
+}
+
 // UpdatePayment atualiza um pagamento existente
 func (d *Database) UpdatePayment(payment *Payment) error {
 	key := []byte(payment.ID)
@@ -89,21 +174,56 @@ func (d *Database) UpdatePayment(payment *Payment) error {
 		if data == nil {
 			return fmt.Errorf("pagamento não encontrado: %s", payment.ID)
 		}
-		var existing Payment
-		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&existing); err != nil {
-			return fmt.Errorf("erro ao decodificar pagamento: %w", err)
+		return updateStored(bucket, key, data, payment)
+	})
+}
+
+// UpsertPayment cria o pagamento se ainda não existir ou, se existir, atualiza
+// status, processor e UpdatedAt como o UpdatePayment; tudo numa transação.
+// Útil quando não se sabe se o CreatePayment chegou a rodar (ex.: após um crash).
+func (d *Database) UpsertPayment(payment *Payment) error {
+	key := []byte(payment.ID)
+	created := false
+	err := d.db.Update(func(tx *goBolt.Tx) error {
+		bucket := tx.Bucket([]byte(paymentsBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s não existe", paymentsBucket)
+		}
+		if data := bucket.Get(key); data != nil {
+			return updateStored(bucket, key, data, payment)
 		}
-		// Atualiza campos
-		existing.Status = payment.Status
-		existing.ProcessorUsed = payment.ProcessorUsed
-		existing.UpdatedAt = payment.UpdatedAt
-		// Serializa novamente
-		var buf bytes.Buffer
-		if err := gob.NewEncoder(&buf).Encode(&existing); err != nil {
+		data, err := encodePayment(payment)
+		if err != nil {
 			return fmt.Errorf("erro ao serializar pagamento: %w", err)
 		}
-		return bucket.Put(key, buf.Bytes())
+		created = true
+		return putPayment(tx, bucket, payment, data)
 	})
+	if err != nil {
+		return fmt.Errorf("erro ao gravar pagamento: %w", err)
+	}
+	if created {
+		atomic.AddInt64(&d.created, 1)
+	}
+	return nil
+}
+
+// updateStored aplica status, processor e UpdatedAt ao pagamento gravado
+func updateStored(bucket *goBolt.Bucket, key, data []byte, payment *Payment) error {
+	var existing Payment
+	if err := decodePayment(data, &existing); err != nil {
+		return fmt.Errorf("erro ao decodificar pagamento: %w", err)
+	}
+	// Atualiza campos (CreatedAt não muda, então o índice por data continua válido)
+	existing.Status = payment.Status
+	existing.ProcessorUsed = payment.ProcessorUsed
+	existing.UpdatedAt = payment.UpdatedAt
+	// Serializa novamente
+	updated, err := encodePayment(&existing)
+	if err != nil {
+		return fmt.Errorf("erro ao serializar pagamento: %w", err)
+	}
+	return bucket.Put(key, updated)
 }
 
 // GetPaymentByID busca um pagamento pelo ID
@@ -120,7 +240,7 @@ func (d *Database) GetPaymentByID(id string) (*Payment, error) {
 			return fmt.Errorf("pagamento não encontrado: %s", id)
 		}
 		var p Payment
-		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&p); err != nil {
+		if err := decodePayment(data, &p); err != nil {
 			return fmt.Errorf("erro ao decodificar pagamento: %w", err)
 		}
 		payment = &p
@@ -140,14 +260,25 @@ func (d *Database) GetPaymentsByCustomer(customerID string) ([]*Payment, error)
 		if bucket == nil {
 			return fmt.Errorf("bucket %s não existe", paymentsBucket)
 		}
-		return bucket.ForEach(func(k, v []byte) error {
+		// Só os IDs do cliente, pelo índice, sem varrer o bucket
+		customers := tx.Bucket([]byte(customersBucket))
+		if customers == nil {
+			return nil
+		}
+		index := customers.Bucket([]byte(customerID))
+		if index == nil {
+			return nil
+		}
+		return index.ForEach(func(k, _ []byte) error {
+			data := bucket.Get(k)
+			if data == nil {
+				return nil
+			}
 			var p Payment
-			if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&p); err != nil {
+			if err := decodePayment(data, &p); err != nil {
 				return err
 			}
-			if p.CustomerID == customerID {
-				payments = append(payments, &p)
-			}
+			payments = append(payments, &p)
 			return nil
 		})
 	})
@@ -172,7 +303,7 @@ func (d *Database) GetPaymentSummary(customerID string) (float64, int, error) {
 		}
 		return bucket.ForEach(func(k, v []byte) error {
 			var p Payment
-			if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&p); err != nil {
+			if err := decodePayment(v, &p); err != nil {
 				return err
 			}
 			if p.CustomerID == customerID && p.Status == "completed" {
@@ -188,84 +319,120 @@ func (d *Database) GetPaymentSummary(customerID string) (float64, int, error) {
 	return totalAmount, count, nil
 }
 
+// Acumulador parcial de GetPaymentStats, um por goroutine de varredura
+type paymentStats struct {
+	totalPayments, completedPayments, processingPayments, errorPayments int
+	totalAmount                                                         float64
+	customerSet                                                         map[string]struct{}
+}
+
 // GetPaymentStats retorna estatísticas gerais dos pagamentos
 func (d *Database) GetPaymentStats() (map[string]interface{}, error) {
-	var totalPayments, completedPayments, processingPayments, errorPayments, uniqueCustomers int
-	var totalAmount float64
-	customerSet := make(map[string]struct{})
-	err := d.db.View(func(tx *goBolt.Tx) error {
-		bucket := tx.Bucket([]byte(paymentsBucket))
-		if bucket == nil {
-			return fmt.Errorf("bucket %s não existe", paymentsBucket)
+	partials, err := parallelScan(d, func() *paymentStats {
+		return &paymentStats{customerSet: make(map[string]struct{})}
+	}, func(s *paymentStats, k, v []byte) error {
+		var p Payment
+		if err := decodePayment(v, &p); err != nil {
+			return err
 		}
-		return bucket.ForEach(func(k, v []byte) error {
-			var p Payment
-			if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&p); err != nil {
-				return err
-			}
-			totalPayments++
-			customerSet[p.CustomerID] = struct{}{}
-			switch p.Status {
-			case "completed":
-				completedPayments++
-				totalAmount += p.Amount
-			case "processing":
-				processingPayments++
-			case "error":
-				errorPayments++
-			}
-			return nil
-		})
+		s.totalPayments++
+		s.customerSet[p.CustomerID] = struct{}{}
+		switch p.Status {
+		case "completed":
+			s.completedPayments++
+			s.totalAmount += p.Amount
+		case "processing":
+			s.processingPayments++
+		case "error":
+			s.errorPayments++
+		}
+		return nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("erro ao buscar estatísticas: %w", err)
 	}
-	uniqueCustomers = len(customerSet)
+	// Merge dos parciais
+	total := &paymentStats{customerSet: make(map[string]struct{})}
+	for _, s := range partials {
+		total.totalPayments += s.totalPayments
+		total.completedPayments += s.completedPayments
+		total.processingPayments += s.processingPayments
+		total.errorPayments += s.errorPayments
+		total.totalAmount += s.totalAmount
+		for c := range s.customerSet {
+			total.customerSet[c] = struct{}{}
+		}
+	}
 	stats := map[string]interface{}{
-		"total_payments":      totalPayments,
-		"completed_payments":  completedPayments,
-		"processing_payments": processingPayments,
-		"error_payments":      errorPayments,
-		"total_amount":        totalAmount,
-		"unique_customers":    uniqueCustomers,
+		"total_payments":      total.totalPayments,
+		"completed_payments":  total.completedPayments,
+		"processing_payments": total.processingPayments,
+		"error_payments":      total.errorPayments,
+		"total_amount":        total.totalAmount,
+		"unique_customers":    len(total.customerSet),
 	}
 	return stats, nil
 }
 
-// CleanupOldPayments remove pagamentos antigos (opcional, para manutenção)
+// CleanupOldPayments remove pagamentos antigos (opcional, para manutenção) em
+// lotes de DB_CLEANUP_BATCH_SIZE, uma transação por lote: a memória fica limitada
+// ao lote e o lock de escrita é liberado entre eles
 func (d *Database) CleanupOldPayments(daysOld int) error {
 	limite := time.Now().AddDate(0, 0, -daysOld)
+	batch := cleanupBatchSize
+	if batch <= 0 {
+		batch = 1000
+	}
+	var removidos int
+	for {
+		n, err := d.cleanupBatch(limite, batch)
+		removidos += n
+		if err != nil {
+			return fmt.Errorf("erro ao limpar pagamentos antigos: %w", err)
+		}
+		if n < batch {
+			break
+		}
+		runtime.Gosched()
+	}
+	log.Printf("[database] %d pagamentos antigos removidos", removidos)
+	return nil
+}
+
+// cleanupBatch remove até max pagamentos com CreatedAt antes de limite, do mais
+// velho para o mais novo, pelo índice por data
+func (d *Database) cleanupBatch(limite time.Time, max int) (int, error) {
 	var removidos int
 	err := d.db.Update(func(tx *goBolt.Tx) error {
 		bucket := tx.Bucket([]byte(paymentsBucket))
 		if bucket == nil {
 			return fmt.Errorf("bucket %s não existe", paymentsBucket)
 		}
-		var keysToDelete [][]byte
-		err := bucket.ForEach(func(k, v []byte) error {
-			var p Payment
-			if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&p); err != nil {
-				return err
+		old := make([]*Payment, 0, max)
+		err := forEachCreatedBetween(tx, time.Time{}, limite, func(p *Payment) error {
+			if !p.CreatedAt.Before(limite) {
+				return nil
 			}
-			if p.CreatedAt.Before(limite) {
-				keysToDelete = append(keysToDelete, append([]byte{}, k...))
+			old = append(old, p)
+			if len(old) == max {
+				return errBatchFull
 			}
 			return nil
 		})
-		if err != nil {
+		if err != nil && !errors.Is(err, errBatchFull) {
 			return err
 		}
-		for _, k := range keysToDelete {
+		// Remoção fora da iteração: o bolt não permite alterar o bucket sob o cursor
+		for _, p := range old {
+			k := []byte(p.ID)
 			if err := bucket.Delete(k); err == nil {
 				removidos++
+				unindexCustomerPayment(tx, p.CustomerID, k)
+				unindexCreatedAt(tx, p.CreatedAt, k)
 			}
 		}
 		return nil
 	})
-	if err != nil {
-		return fmt.Errorf("erro ao limpar pagamentos antigos: %w", err)
-	}
-	log.Printf("[database] %d pagamentos antigos removidos", removidos)
-	return nil
+	return removidos, err
 }
  
\ No newline at end of file
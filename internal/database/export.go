@@ -0,0 +1,88 @@
+package database
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	goBolt "go.etcd.io/bbolt"
+)
+
+// Cabeçalho do CSV exportado, na ordem dos campos do Payment
+var exportCSVHeader = []string{"id", "customer_id", "amount", "description", "status", "processor_used", "requested_at", "created_at", "updated_at"}
+
+// ExportJSON escreve todos os pagamentos como um array JSON, registro a
+// registro, sem carregar o bucket em memória
+func (d *Database) ExportJSON(w io.Writer) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	first := true
+	err := d.forEachStored(func(_, v []byte) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		// Já gravados em JSON: vão direto, sem decodificar
+		_, err := w.Write(v)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao exportar pagamentos: %w", err)
+	}
+	_, err = io.WriteString(w, "]\n")
+	return err
+}
+
+// ExportCSV escreve todos os pagamentos em CSV com cabeçalho, registro a registro
+func (d *Database) ExportCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportCSVHeader); err != nil {
+		return err
+	}
+	err := d.forEachStored(func(_, v []byte) error {
+		var p Payment
+		if err := decodePayment(v, &p); err != nil {
+			return err
+		}
+		return cw.Write([]string{
+			p.ID,
+			p.CustomerID,
+			strconv.FormatFloat(p.Amount, 'f', -1, 64),
+			p.Description,
+			p.Status,
+			p.ProcessorUsed,
+			formatOptionalTime(p.RequestedAt),
+			p.CreatedAt.Format(time.RFC3339Nano),
+			p.UpdatedAt.Format(time.RFC3339Nano),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao exportar pagamentos: %w", err)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// forEachStored percorre os pagamentos gravados numa única transação de leitura
+func (d *Database) forEachStored(fn func(k, v []byte) error) error {
+	return d.db.View(func(tx *goBolt.Tx) error {
+		bucket := tx.Bucket([]byte(paymentsBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s não existe", paymentsBucket)
+		}
+		return bucket.ForEach(fn)
+	})
+}
+
+// formatOptionalTime deixa vazio o instante desconhecido
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	goBolt "go.etcd.io/bbolt"
+)
+
+// IDs de pagamentos já processados (idempotência), com o instante da marcação
+const processedBucket = "processed"
+
+// MarkProcessed registra o ID como processado agora
+func (d *Database) MarkProcessed(id string) error {
+	var at [8]byte
+	binary.BigEndian.PutUint64(at[:], uint64(time.Now().UnixNano()))
+	err := d.db.Update(func(tx *goBolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(processedBucket))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(id), at[:])
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao marcar pagamento processado: %w", err)
+	}
+	return nil
+}
+
+// IsProcessed indica se o ID já foi marcado como processado
+func (d *Database) IsProcessed(id string) (bool, error) {
+	var found bool
+	err := d.db.View(func(tx *goBolt.Tx) error {
+		bucket := tx.Bucket([]byte(processedBucket))
+		found = bucket != nil && bucket.Get([]byte(id)) != nil
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("erro ao consultar pagamento processado: %w", err)
+	}
+	return found, nil
+}
+
+// LoadProcessed retorna todos os IDs processados com o instante da marcação
+func (d *Database) LoadProcessed() (map[string]time.Time, error) {
+	ids := make(map[string]time.Time)
+	err := d.db.View(func(tx *goBolt.Tx) error {
+		bucket := tx.Bucket([]byte(processedBucket))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			if len(v) != 8 {
+				return nil
+			}
+			ids[string(k)] = time.Unix(0, int64(binary.BigEndian.Uint64(v)))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao carregar pagamentos processados: %w", err)
+	}
+	return ids, nil
+}
+
+// PruneProcessed remove os IDs marcados antes de limite, em lotes de
+// DB_CLEANUP_BATCH_SIZE (uma transação por lote), e retorna quantos removeu
+func (d *Database) PruneProcessed(limite time.Time) (int, error) {
+	batch := cleanupBatchSize
+	if batch <= 0 {
+		batch = 1000
+	}
+	var removidos int
+	for {
+		n, err := d.pruneProcessedBatch(limite, batch)
+		removidos += n
+		if err != nil {
+			return removidos, fmt.Errorf("erro ao remover pagamentos processados antigos: %w", err)
+		}
+		if n < batch {
+			return removidos, nil
+		}
+	}
+}
+
+// pruneProcessedBatch remove até max IDs marcados antes de limite
+func (d *Database) pruneProcessedBatch(limite time.Time, max int) (int, error) {
+	cutoff := limite.UnixNano()
+	var removidos int
+	err := d.db.Update(func(tx *goBolt.Tx) error {
+		bucket := tx.Bucket([]byte(processedBucket))
+		if bucket == nil {
+			return nil
+		}
+		old := make([][]byte, 0, max)
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil && len(old) < max; k, v = c.Next() {
+			if len(v) == 8 && int64(binary.BigEndian.Uint64(v)) < cutoff {
+				old = append(old, append([]byte{}, k...))
+			}
+		}
+		// Remoção fora da iteração: o bolt não permite alterar o bucket sob o cursor
+		for _, k := range old {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+			removidos++
+		}
+		return nil
+	})
+	return removidos, err
+}
+
+// ClearProcessed remove todos os IDs processados
+func (d *Database) ClearProcessed() error {
+	err := d.db.Update(func(tx *goBolt.Tx) error {
+		if tx.Bucket([]byte(processedBucket)) == nil {
+			return nil
+		}
+		return tx.DeleteBucket([]byte(processedBucket))
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao limpar pagamentos processados: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	goBolt "go.etcd.io/bbolt"
+)
+
+// markProcessedAt grava o ID com um instante de marcação arbitrário
+func markProcessedAt(t *testing.T, db *Database, id string, at time.Time) {
+	t.Helper()
+	var v [8]byte
+	binary.BigEndian.PutUint64(v[:], uint64(at.UnixNano()))
+	err := db.db.Update(func(tx *goBolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(processedBucket))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(id), v[:])
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPruneProcessedRemovesOnlyExpired(t *testing.T) {
+	db := newTestDatabase(t)
+	withCleanupBatchSize(t, 100)
+	old := time.Now().Add(-time.Hour)
+	for i := 0; i < 250; i++ {
+		markProcessedAt(t, db, fmt.Sprintf("old-%03d", i), old)
+	}
+	for i := 0; i < 5; i++ {
+		if err := db.MarkProcessed(fmt.Sprintf("new-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Vários lotes: 100 + 100 + 50
+	n, err := db.PruneProcessed(time.Now().Add(-10 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 250 {
+		t.Fatalf("pruned %d, want 250", n)
+	}
+	ids, err := db.LoadProcessed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 5 {
+		t.Fatalf("remaining IDs = %d, want the 5 recent ones", len(ids))
+	}
+	if seen, _ := db.IsProcessed("old-000"); seen {
+		t.Fatal("expired ID still marked as processed")
+	}
+	if seen, _ := db.IsProcessed("new-0"); !seen {
+		t.Fatal("recent ID was pruned")
+	}
+}
+
+func TestPruneProcessedWithoutBucket(t *testing.T) {
+	db := newTestDatabase(t)
+	if n, err := db.PruneProcessed(time.Now()); err != nil || n != 0 {
+		t.Fatalf("PruneProcessed on an empty database = %d, %v; want 0, nil", n, err)
+	}
+}
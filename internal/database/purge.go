@@ -0,0 +1,55 @@
+package database
+
+import (
+	"fmt"
+
+	goBolt "go.etcd.io/bbolt"
+)
+
+// DeletePayment remove o pagamento e suas entradas nos índices
+func (d *Database) DeletePayment(id string) error {
+	key := []byte(id)
+	return d.db.Update(func(tx *goBolt.Tx) error {
+		bucket := tx.Bucket([]byte(paymentsBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s não existe", paymentsBucket)
+		}
+		data := bucket.Get(key)
+		if data == nil {
+			return fmt.Errorf("pagamento não encontrado: %s", id)
+		}
+		var p Payment
+		if err := decodePayment(data, &p); err != nil {
+			return fmt.Errorf("erro ao decodificar pagamento: %w", err)
+		}
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+		unindexCustomerPayment(tx, p.CustomerID, key)
+		unindexCreatedAt(tx, p.CreatedAt, key)
+		return nil
+	})
+}
+
+// PurgeAllPayments apaga e recria o bucket de pagamentos e os índices, numa
+// única transação
+func (d *Database) PurgeAllPayments() error {
+	err := d.db.Update(func(tx *goBolt.Tx) error {
+		for _, name := range []string{paymentsBucket, customersBucket, createdAtBucket} {
+			if tx.Bucket([]byte(name)) != nil {
+				if err := tx.DeleteBucket([]byte(name)); err != nil {
+					return err
+				}
+			}
+			if _, err := tx.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao apagar pagamentos: %w", err)
+	}
+	d.invalidateStats()
+	return nil
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "payments.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// seedPayments grava n pagamentos do cliente com o CreatedAt informado
+func seedPayments(t *testing.T, db *Database, prefix, customerID string, n int, createdAt time.Time) {
+	t.Helper()
+	payments := make([]*Payment, n)
+	for i := range payments {
+		payments[i] = &Payment{
+			ID:         fmt.Sprintf("%s-%05d", prefix, i),
+			CustomerID: customerID,
+			Amount:     1,
+			Status:     "completed",
+			CreatedAt:  createdAt.Add(time.Duration(i) * time.Millisecond),
+			UpdatedAt:  createdAt,
+		}
+	}
+	if err := db.CreatePaymentsBatch(payments); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func withCleanupBatchSize(t *testing.T, n int) {
+	t.Helper()
+	prev := cleanupBatchSize
+	cleanupBatchSize = n
+	t.Cleanup(func() { cleanupBatchSize = prev })
+}
+
+func TestCleanupBatchCommitsEachBatch(t *testing.T) {
+	db := newTestDatabase(t)
+	limite := time.Now().AddDate(0, 0, -30)
+	seedPayments(t, db, "old", "c-old", 250, limite.AddDate(0, 0, -10))
+	seedPayments(t, db, "new", "c-new", 5, time.Now())
+
+	// Cada lote é uma transação própria: o que ele removeu já some para a leitura seguinte
+	for _, want := range []int{100, 100, 50, 0} {
+		n, err := db.cleanupBatch(limite, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Fatalf("batch removed %d, want %d", n, want)
+		}
+		left, err := db.CountPaymentsInWindow(time.Time{}, limite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		remaining, _ := db.CountPaymentsByCustomer("c-old")
+		if left != remaining {
+			t.Fatalf("time index has %d old payments, customer index %d", left, remaining)
+		}
+	}
+	if n, _ := db.CountPaymentsByCustomer("c-new"); n != 5 {
+		t.Fatalf("recent payments = %d, want 5 untouched", n)
+	}
+}
+
+func TestCleanupOldPaymentsAcrossBatchBoundaries(t *testing.T) {
+	cases := []struct {
+		name       string
+		old, batch int
+	}{
+		{"partial last batch", 2500, 1000},
+		{"exact multiple", 2000, 1000},
+		{"single batch", 10, 1000},
+		{"batch of one", 3, 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			withCleanupBatchSize(t, tc.batch)
+			db := newTestDatabase(t)
+			seedPayments(t, db, "old", "c-old", tc.old, time.Now().AddDate(0, 0, -40))
+			seedPayments(t, db, "new", "c-new", 10, time.Now())
+
+			if err := db.CleanupOldPayments(30); err != nil {
+				t.Fatal(err)
+			}
+			if n, _ := db.CountPaymentsByCustomer("c-old"); n != 0 {
+				t.Fatalf("old payments left = %d, want 0", n)
+			}
+			if _, err := db.GetPaymentByID("old-00000"); err == nil {
+				t.Fatal("old payment still readable")
+			}
+			if n, _ := db.CountPaymentsByCustomer("c-new"); n != 10 {
+				t.Fatalf("recent payments = %d, want 10", n)
+			}
+			if _, err := db.GetPaymentByID("new-00009"); err != nil {
+				t.Fatalf("recent payment removed: %v", err)
+			}
+		})
+	}
+}
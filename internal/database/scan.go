@@ -0,0 +1,142 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	goBolt "go.etcd.io/bbolt"
+)
+
+// SetScanWorkers define quantas goroutines dividem as varreduras completas do
+// bucket (cada uma com sua própria transação de leitura). 1 = sequencial.
+func (d *Database) SetScanWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	d.scanWorkers = n
+}
+
+// keyRange é um intervalo [start, end) de chaves; nil significa aberto
+type keyRange struct {
+	start, end []byte
+}
+
+// splitKeyRanges divide o bucket em até n intervalos com quantidades de chaves
+// parecidas, andando pelo cursor sem decodificar os valores
+func (d *Database) splitKeyRanges(n int) ([]keyRange, error) {
+	if n <= 1 {
+		return []keyRange{{}}, nil
+	}
+	var bounds [][]byte
+	err := d.db.View(func(tx *goBolt.Tx) error {
+		bucket := tx.Bucket([]byte(paymentsBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s não existe", paymentsBucket)
+		}
+		total := bucket.Stats().KeyN
+		step := total / n
+		if step == 0 {
+			return nil
+		}
+		i := 0
+		c := bucket.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			if i > 0 && i%step == 0 && len(bounds) < n-1 {
+				bounds = append(bounds, append([]byte{}, k...))
+			}
+			i++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := make([]keyRange, 0, len(bounds)+1)
+	var start []byte
+	for _, b := range bounds {
+		ranges = append(ranges, keyRange{start: start, end: b})
+		start = b
+	}
+	return append(ranges, keyRange{start: start}), nil
+}
+
+// scanRange percorre as chaves do intervalo em uma transação de leitura própria
+func (d *Database) scanRange(r keyRange, fn func(k, v []byte) error) error {
+	return d.db.View(func(tx *goBolt.Tx) error {
+		bucket := tx.Bucket([]byte(paymentsBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s não existe", paymentsBucket)
+		}
+		c := bucket.Cursor()
+		var k, v []byte
+		if r.start == nil {
+			k, v = c.First()
+		} else {
+			k, v = c.Seek(r.start)
+		}
+		for ; k != nil; k, v = c.Next() {
+			if r.end != nil && bytes.Compare(k, r.end) >= 0 {
+				break
+			}
+			if err := fn(k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// parallelScan divide o bucket entre scanWorkers goroutines; newPartial cria o
+// acumulador de cada goroutine e os parciais são devolvidos para o merge
+func parallelScan[T any](d *Database, newPartial func() T, fn func(partial T, k, v []byte) error) ([]T, error) {
+	ranges, err := d.splitKeyRanges(d.scanWorkers)
+	if err != nil {
+		return nil, err
+	}
+	partials := make([]T, len(ranges))
+	errs := make([]error, len(ranges))
+	var wg sync.WaitGroup
+	for i, r := range ranges {
+		partials[i] = newPartial()
+		wg.Add(1)
+		go func(i int, r keyRange) {
+			defer wg.Done()
+			errs[i] = d.scanRange(r, func(k, v []byte) error {
+				return fn(partials[i], k, v)
+			})
+		}(i, r)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return partials, nil
+}
+
+// GetPaymentsByStatus retorna os pagamentos com o status informado
+func (d *Database) GetPaymentsByStatus(status string) ([]*Payment, error) {
+	partials, err := parallelScan(d, func() *[]*Payment {
+		return new([]*Payment)
+	}, func(found *[]*Payment, k, v []byte) error {
+		var p Payment
+		if err := decodePayment(v, &p); err != nil {
+			return err
+		}
+		if p.Status == status {
+			*found = append(*found, &p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar pagamentos por status: %w", err)
+	}
+	var payments []*Payment
+	for _, found := range partials {
+		payments = append(payments, *found...)
+	}
+	return payments, nil
+}
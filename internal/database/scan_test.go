@@ -0,0 +1,113 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// seedMixedPayments grava n pagamentos com status, clientes e valores variados;
+// os valores são múltiplos de 0.5 para a soma não depender da ordem do merge
+func seedMixedPayments(tb testing.TB, db *Database, n int) {
+	tb.Helper()
+	statuses := []string{"completed", "processing", "error", "completed"}
+	now := time.Now()
+	payments := make([]*Payment, 0, 1000)
+	flush := func() {
+		if err := db.CreatePaymentsBatch(payments); err != nil {
+			tb.Fatal(err)
+		}
+		payments = payments[:0]
+	}
+	for i := 0; i < n; i++ {
+		payments = append(payments, &Payment{
+			ID:         fmt.Sprintf("p-%07d", i),
+			CustomerID: fmt.Sprintf("c-%03d", i%317),
+			Amount:     float64(i%40) * 0.5,
+			Status:     statuses[i%len(statuses)],
+			CreatedAt:  now.Add(time.Duration(i) * time.Millisecond),
+			UpdatedAt:  now,
+		})
+		if len(payments) == cap(payments) {
+			flush()
+		}
+	}
+	if len(payments) > 0 {
+		flush()
+	}
+}
+
+func TestGetPaymentStatsParallelMatchesSequential(t *testing.T) {
+	db := newTestDatabase(t)
+	seedMixedPayments(t, db, 5000)
+
+	db.SetScanWorkers(1)
+	want, err := db.GetPaymentStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want["total_payments"] != 5000 {
+		t.Fatalf("total_payments = %v, want 5000", want["total_payments"])
+	}
+	for _, workers := range []int{2, 3, 4, 8} {
+		db.SetScanWorkers(workers)
+		got, err := db.GetPaymentStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("workers=%d: stats = %v, want %v", workers, got, want)
+		}
+	}
+}
+
+func TestGetPaymentsByStatusParallelMatchesSequential(t *testing.T) {
+	db := newTestDatabase(t)
+	seedMixedPayments(t, db, 2000)
+
+	count := func(workers int) map[string]bool {
+		db.SetScanWorkers(workers)
+		payments, err := db.GetPaymentsByStatus("error")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make(map[string]bool, len(payments))
+		for _, p := range payments {
+			if ids[p.ID] {
+				t.Fatalf("workers=%d: payment %s returned twice", workers, p.ID)
+			}
+			ids[p.ID] = true
+		}
+		return ids
+	}
+	want := count(1)
+	if len(want) != 500 {
+		t.Fatalf("sequential scan found %d error payments, want 500", len(want))
+	}
+	if got := count(4); !reflect.DeepEqual(got, want) {
+		t.Fatalf("parallel scan found %d payments, want the same %d", len(got), len(want))
+	}
+}
+
+func BenchmarkGetPaymentStats(b *testing.B) {
+	db, err := NewDatabase(filepath.Join(b.TempDir(), "payments.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	seedMixedPayments(b, db, 100000)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			db.SetScanWorkers(workers)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := db.GetPaymentStats(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	goBolt "go.etcd.io/bbolt"
+)
+
+const snapshotsBucket = "snapshots"
+
+// SaveSnapshots grava todos os snapshots numa única transação: ou todos
+// ficam persistidos, ou nenhum (mantém estados relacionados consistentes)
+func (d *Database) SaveSnapshots(snapshots map[string]interface{}) error {
+	encoded := make(map[string][]byte, len(snapshots))
+	for name, v := range snapshots {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+			return fmt.Errorf("erro ao serializar snapshot %s: %w", name, err)
+		}
+		encoded[name] = buf.Bytes()
+	}
+
+	err := d.db.Update(func(tx *goBolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(snapshotsBucket))
+		if err != nil {
+			return err
+		}
+		for name, data := range encoded {
+			if err := bucket.Put([]byte(name), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao gravar snapshots: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshot lê o snapshot gravado com o nome informado; retorna false se não existir
+func (d *Database) LoadSnapshot(name string, v interface{}) (bool, error) {
+	var data []byte
+	err := d.db.View(func(tx *goBolt.Tx) error {
+		bucket := tx.Bucket([]byte(snapshotsBucket))
+		if bucket == nil {
+			return nil
+		}
+		if raw := bucket.Get([]byte(name)); raw != nil {
+			data = append([]byte(nil), raw...)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("erro ao ler snapshot %s: %w", name, err)
+	}
+	if data == nil {
+		return false, nil
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		return false, fmt.Errorf("erro ao desserializar snapshot %s: %w", name, err)
+	}
+	return true, nil
+}
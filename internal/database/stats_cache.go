@@ -0,0 +1,48 @@
+package database
+
+import "time"
+
+const statsCacheKey = "payment_stats"
+
+// GetPaymentStatsCached devolve o GetPaymentStats guardado por até ttl, para
+// consultas repetidas (dashboards) não dispararem uma varredura completa cada.
+// Misses simultâneos esperam a mesma varredura em vez de rodar uma cada.
+// Cada varredura lê de transações de leitura do bolt, que não bloqueiam escritas.
+func (d *Database) GetPaymentStatsCached(ttl time.Duration) (map[string]interface{}, error) {
+	if ttl <= 0 {
+		return d.GetPaymentStats()
+	}
+	if stats, ok := d.statsCache.Get(statsCacheKey); ok {
+		return copyStats(stats), nil
+	}
+	d.statsMu.Lock()
+	defer d.statsMu.Unlock()
+	if stats, ok := d.statsCache.Get(statsCacheKey); ok {
+		return copyStats(stats), nil
+	}
+	gen := d.statsGen.Load()
+	stats, err := d.GetPaymentStats()
+	if err != nil {
+		return nil, err
+	}
+	// Purge durante a varredura: o resultado já nasceu velho, não guarda
+	if d.statsGen.Load() == gen {
+		d.statsCache.Set(statsCacheKey, stats, ttl)
+	}
+	return copyStats(stats), nil
+}
+
+// invalidateStats descarta as estatísticas guardadas (e as que estão sendo calculadas)
+func (d *Database) invalidateStats() {
+	d.statsGen.Add(1)
+	d.statsCache.Delete(statsCacheKey)
+}
+
+// copyStats evita que o chamador altere o mapa compartilhado pelo cache
+func copyStats(stats map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(stats))
+	for k, v := range stats {
+		c[k] = v
+	}
+	return c
+}
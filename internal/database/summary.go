@@ -0,0 +1,112 @@
+package database
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	goBolt "go.etcd.io/bbolt"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+// Distância máxima entre o requestedAt e o CreatedAt de um pagamento (latência
+// do processor mais diferença de relógio entre os serviços)
+var requestedAtSkew = config.GetDuration("DB_REQUESTED_AT_SKEW", 10*time.Second)
+
+const (
+	summaryBucket = "summary"
+	summaryKey    = "totals"
+)
+
+// ProcessorSummary são os totais acumulados de um processor
+type ProcessorSummary struct {
+	TotalRequests int
+	TotalAmount   float64
+}
+
+type summaryRecord struct {
+	Default  ProcessorSummary
+	Fallback ProcessorSummary
+}
+
+// SaveSummary grava os totais dos dois processors
+func (d *Database) SaveSummary(def, fallback ProcessorSummary) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(summaryRecord{Default: def, Fallback: fallback}); err != nil {
+		return fmt.Errorf("erro ao serializar resumo: %w", err)
+	}
+	err := d.db.Update(func(tx *goBolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(summaryBucket))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(summaryKey), buf.Bytes())
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao gravar resumo: %w", err)
+	}
+	return nil
+}
+
+// LoadSummary lê os totais gravados; sem registro, retorna zeros
+func (d *Database) LoadSummary() (def, fallback ProcessorSummary, err error) {
+	var record summaryRecord
+	err = d.db.View(func(tx *goBolt.Tx) error {
+		bucket := tx.Bucket([]byte(summaryBucket))
+		if bucket == nil {
+			return nil
+		}
+		data := bucket.Get([]byte(summaryKey))
+		if data == nil {
+			return nil
+		}
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(&record)
+	})
+	if err != nil {
+		return ProcessorSummary{}, ProcessorSummary{}, fmt.Errorf("erro ao ler resumo: %w", err)
+	}
+	return record.Default, record.Fallback, nil
+}
+
+// GetSummaryByRange soma os pagamentos concluídos com RequestedAt em [from, to]
+// (CreatedAt quando o requestedAt é desconhecido), agrupados por ProcessorUsed;
+// from/to zerados deixam o intervalo aberto. O RequestedAt é o mesmo instante que
+// o processor registrou, então o resumo bate com a janela dele.
+func (d *Database) GetSummaryByRange(from, to time.Time) (map[string]ProcessorSummary, error) {
+	// O índice é por CreatedAt, gravado depois da resposta do processor: a janela
+	// percorrida é alargada em DB_REQUESTED_AT_SKEW e o filtro exato vem depois
+	walkFrom, walkTo := from, to
+	if !walkFrom.IsZero() {
+		walkFrom = walkFrom.Add(-requestedAtSkew)
+	}
+	if !walkTo.IsZero() {
+		walkTo = walkTo.Add(requestedAtSkew)
+	}
+	summaries := make(map[string]ProcessorSummary)
+	err := d.db.View(func(tx *goBolt.Tx) error {
+		// Percorre só a janela pelo índice por data
+		return forEachCreatedBetween(tx, walkFrom, walkTo, func(p *Payment) error {
+			if p.Status != "completed" || p.ProcessorUsed == "" {
+				return nil
+			}
+			at := p.RequestedAt
+			if at.IsZero() {
+				at = p.CreatedAt
+			}
+			if (!from.IsZero() && at.Before(from)) || (!to.IsZero() && at.After(to)) {
+				return nil
+			}
+			summary := summaries[p.ProcessorUsed]
+			summary.TotalRequests++
+			summary.TotalAmount += p.Amount
+			summaries[p.ProcessorUsed] = summary
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao calcular resumo por período: %w", err)
+	}
+	return summaries, nil
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	goBolt "go.etcd.io/bbolt"
+)
+
+// Índice por data: chave = CreatedAt (UTC, RFC3339Nano com fração fixa) + ID,
+// valor vazio. A fração fixa mantém a ordem lexicográfica igual à temporal,
+// então consultas por período usam Cursor.Seek em vez de varrer o bucket.
+const createdAtBucket = "payments_by_created_at"
+
+const createdAtLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+var createdAtKeyLen = len(createdAtPrefix(time.Time{}))
+
+func createdAtPrefix(t time.Time) []byte {
+	return []byte(t.UTC().Format(createdAtLayout))
+}
+
+func createdAtKey(t time.Time, paymentID []byte) []byte {
+	return append(createdAtPrefix(t), paymentID...)
+}
+
+func indexCreatedAt(tx *goBolt.Tx, createdAt time.Time, paymentID []byte) error {
+	bucket := tx.Bucket([]byte(createdAtBucket))
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Put(createdAtKey(createdAt, paymentID), []byte{})
+}
+
+func unindexCreatedAt(tx *goBolt.Tx, createdAt time.Time, paymentID []byte) {
+	if bucket := tx.Bucket([]byte(createdAtBucket)); bucket != nil {
+		bucket.Delete(createdAtKey(createdAt, paymentID))
+	}
+}
+
+// backfillCreatedAtIndex cria o índice a partir dos pagamentos já gravados;
+// roda só quando o bucket do índice ainda não existe (bancos anteriores a ele)
+func backfillCreatedAtIndex(tx *goBolt.Tx) error {
+	if tx.Bucket([]byte(createdAtBucket)) != nil {
+		return nil
+	}
+	index, err := tx.CreateBucket([]byte(createdAtBucket))
+	if err != nil {
+		return err
+	}
+	return tx.Bucket([]byte(paymentsBucket)).ForEach(func(k, v []byte) error {
+		var p Payment
+		if err := decodePayment(v, &p); err != nil {
+			return err
+		}
+		return index.Put(createdAtKey(p.CreatedAt, k), []byte{})
+	})
+}
+
+// walkCreatedAt percorre as chaves do índice com CreatedAt em [from, to], em
+// ordem crescente; from/to zerados deixam o intervalo aberto
+func walkCreatedAt(index *goBolt.Bucket, from, to time.Time, fn func(k []byte) error) error {
+	var upper []byte
+	if !to.IsZero() {
+		upper = createdAtPrefix(to)
+	}
+	c := index.Cursor()
+	var k []byte
+	if from.IsZero() {
+		k, _ = c.First()
+	} else {
+		k, _ = c.Seek(createdAtPrefix(from))
+	}
+	for ; k != nil; k, _ = c.Next() {
+		if len(k) < createdAtKeyLen {
+			continue
+		}
+		if upper != nil && bytes.Compare(k[:createdAtKeyLen], upper) > 0 {
+			return nil
+		}
+		if err := fn(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachCreatedBetween chama fn para cada pagamento com CreatedAt em [from, to]
+func forEachCreatedBetween(tx *goBolt.Tx, from, to time.Time, fn func(p *Payment) error) error {
+	index := tx.Bucket([]byte(createdAtBucket))
+	payments := tx.Bucket([]byte(paymentsBucket))
+	if index == nil || payments == nil {
+		return fmt.Errorf("bucket %s não existe", createdAtBucket)
+	}
+	return walkCreatedAt(index, from, to, func(k []byte) error {
+		data := payments.Get(k[createdAtKeyLen:])
+		if data == nil {
+			return nil
+		}
+		var p Payment
+		if err := decodePayment(data, &p); err != nil {
+			return err
+		}
+		return fn(&p)
+	})
+}
+
+// CountPaymentsInWindow conta os pagamentos com CreatedAt em [from, to] só
+// pelas chaves do índice por data, sem ler nem decodificar os pagamentos
+func (d *Database) CountPaymentsInWindow(from, to time.Time) (int, error) {
+	var count int
+	err := d.db.View(func(tx *goBolt.Tx) error {
+		index := tx.Bucket([]byte(createdAtBucket))
+		if index == nil {
+			return fmt.Errorf("bucket %s não existe", createdAtBucket)
+		}
+		return walkCreatedAt(index, from, to, func([]byte) error {
+			count++
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("erro ao contar pagamentos: %w", err)
+	}
+	return count, nil
+}
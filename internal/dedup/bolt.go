@@ -0,0 +1,80 @@
+package dedup
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
+)
+
+// Bolt mantém os IDs em memória e os grava no BoltDB (bucket "processed"),
+// recarregados na inicialização para a idempotência sobreviver a restarts
+type Bolt struct {
+	*Memory
+	db *database.Database
+}
+
+// NewBolt abre o banco de deduplicação e recarrega os IDs ainda válidos
+func NewBolt(opts Options) (*Bolt, error) {
+	if opts.BoltPath == "" {
+		return nil, fmt.Errorf("caminho do banco de deduplicação não informado")
+	}
+	db, err := database.NewDatabase(opts.BoltPath)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := db.LoadProcessed()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	// Recarrega do mais antigo ao mais novo: o limite de entradas descarta pela frente
+	order := make([]string, 0, len(ids))
+	for id := range ids {
+		order = append(order, id)
+	}
+	sort.Slice(order, func(i, j int) bool { return ids[order[i]].Before(ids[order[j]]) })
+	mem := NewMemory(opts)
+	now := time.Now()
+	// Expirados não voltam para a memória e já saem do banco
+	if opts.TTL > 0 {
+		if _, err := db.PruneProcessed(now.Add(-opts.TTL)); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	for _, id := range order {
+		if markedAt := ids[id]; !mem.expired(markedAt, now) {
+			mem.markAt(id, markedAt)
+		}
+	}
+	return &Bolt{Memory: mem, db: db}, nil
+}
+
+func (b *Bolt) Mark(id string) error {
+	b.Memory.markAt(id, time.Now())
+	return b.db.MarkProcessed(id)
+}
+
+// EvictExpired remove os IDs vencidos da memória e do bucket "processed"
+func (b *Bolt) EvictExpired() int {
+	removed := b.Memory.EvictExpired()
+	if b.ttl > 0 {
+		if _, err := b.db.PruneProcessed(time.Now().Add(-b.ttl)); err != nil {
+			log.Printf("[dedup] %v", err)
+		}
+	}
+	return removed
+}
+
+func (b *Bolt) Clear() error {
+	b.Memory.Clear()
+	return b.db.ClearProcessed()
+}
+
+func (b *Bolt) Close() error {
+	b.Memory.Close()
+	return b.db.Close()
+}
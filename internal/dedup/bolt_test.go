@@ -0,0 +1,40 @@
+package dedup
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/database"
+)
+
+func TestBoltEvictExpiredPrunesBucket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.db")
+	b, err := NewBolt(Options{TTL: 40 * time.Millisecond, BoltPath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Mark("old-1")
+	b.Mark("old-2")
+	time.Sleep(60 * time.Millisecond)
+	b.Mark("fresh")
+
+	if removed := b.EvictExpired(); removed != 2 {
+		t.Fatalf("evicted %d from memory, want 2", removed)
+	}
+	b.Close()
+
+	// O bucket também perdeu os expirados, não só a memória
+	db, err := database.NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ids, err := db.LoadProcessed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ids["fresh"]; len(ids) != 1 || !ok {
+		t.Fatalf("persisted IDs = %v, want only fresh", ids)
+	}
+}
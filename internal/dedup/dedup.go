@@ -0,0 +1,130 @@
+package dedup
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/cachereg"
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/config"
+)
+
+// Deduper registra os IDs de pagamentos já processados (idempotência)
+type Deduper interface {
+	// Seen indica se o ID já foi processado e ainda está dentro do TTL
+	Seen(id string) (bool, error)
+	// Mark registra o ID como processado
+	Mark(id string) error
+	// Len retorna quantos IDs estão registrados
+	Len() (int, error)
+	// Clear remove todos os IDs registrados (purge administrativo)
+	Clear() error
+	// Close libera os recursos do backend
+	Close() error
+}
+
+// Política quando o backend falha: fail-closed (padrão) rejeita o pagamento,
+// fail-open processa mesmo assim, aceitando possíveis duplicatas
+var failurePolicy = config.GetString("DEDUP_FAILURE_POLICY", "fail-closed")
+
+// FailOpen indica se pagamentos devem seguir quando o Deduper está indisponível
+func FailOpen() bool {
+	return failurePolicy == "fail-open"
+}
+
+// SetFailurePolicy troca a política (fail-open ou fail-closed) e retorna a anterior
+func SetFailurePolicy(policy string) string {
+	prev := failurePolicy
+	failurePolicy = policy
+	return prev
+}
+
+// Options define a semântica comum a todos os backends
+type Options struct {
+	TTL           time.Duration // 0 = IDs nunca expiram
+	MaxEntries    int           // 0 = sem limite; ao exceder, o ID mais antigo é descartado
+	SweepInterval time.Duration // backends em memória: varredura dos expirados (0 = só sob demanda)
+	FilePath      string        // backend "file"
+	BoltPath      string        // backend "bolt"
+	RedisAddr     string        // backend "redis"
+	RedisKey      string        // backend "redis"
+}
+
+// New cria o Deduper do backend informado: memory, file, bolt ou redis.
+// Nos backends em memória, inicia o sweeper dos IDs expirados (que nos
+// backends persistentes também limpa o que está gravado).
+func New(backend string, opts Options) (Deduper, error) {
+	var mem *Memory
+	var d Deduper
+	switch backend {
+	case "", "memory":
+		mem = NewMemory(opts)
+		d = mem
+	case "file":
+		f, err := NewFile(opts)
+		if err != nil {
+			return nil, err
+		}
+		mem, d = f.Memory, f
+	case "bolt":
+		b, err := NewBolt(opts)
+		if err != nil {
+			return nil, err
+		}
+		mem, d = b.Memory, b
+	case "redis":
+		return NewRedis(opts)
+	default:
+		return nil, fmt.Errorf("backend de deduplicação desconhecido: %s", backend)
+	}
+	mem.startSweeper(opts.SweepInterval, d.(expirer).EvictExpired)
+	return d, nil
+}
+
+// NewFromEnv cria o Deduper a partir de DEDUP_BACKEND (padrão: memory),
+// DEDUP_TTL, DEDUP_MAX_ENTRIES, DEDUP_SWEEP_INTERVAL, DEDUP_FILE, DEDUP_BOLT_PATH,
+// REDIS_ADDR e DEDUP_REDIS_KEY. A idempotência só importa por alguns minutos,
+// então por padrão os IDs expiram em 10min e o total fica limitado a 1M.
+func NewFromEnv() (Deduper, error) {
+	return New(config.GetString("DEDUP_BACKEND", "memory"), Options{
+		TTL:           config.GetDuration("DEDUP_TTL", 10*time.Minute),
+		MaxEntries:    config.GetInt("DEDUP_MAX_ENTRIES", 1000000),
+		SweepInterval: config.GetDuration("DEDUP_SWEEP_INTERVAL", 30*time.Second),
+		FilePath:      config.GetString("DEDUP_FILE", "data/dedup.log"),
+		BoltPath:      config.GetString("DEDUP_BOLT_PATH", "data/dedup.db"),
+		RedisAddr:     config.GetString("REDIS_ADDR", "redis:6379"),
+		RedisKey:      config.GetString("DEDUP_REDIS_KEY", "rinha:dedup"),
+	})
+}
+
+// expirer é implementado pelos backends que mantêm os IDs em memória
+type expirer interface {
+	EvictExpired() int
+}
+
+// snapshotter é implementado pelos backends cujo estado vive no processo
+type snapshotter interface {
+	Snapshot() map[string]time.Time
+}
+
+// Snapshot copia o estado do Deduper quando ele vive no processo; backends
+// externos (redis) já persistem por conta própria e retornam false
+func Snapshot(d Deduper) (map[string]time.Time, bool) {
+	s, ok := d.(snapshotter)
+	if !ok {
+		return nil, false
+	}
+	return s.Snapshot(), true
+}
+
+// RegisterCache registra o Deduper no registro de caches; backends em memória
+// podem ser reduzidos removendo IDs expirados
+func RegisterCache(d Deduper) {
+	var evict func()
+	if e, ok := d.(expirer); ok {
+		evict = func() { e.EvictExpired() }
+	}
+	cachereg.Register("dedup", func() int {
+		n, _ := d.Len()
+		return n
+	}, evict)
+}
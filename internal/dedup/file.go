@@ -0,0 +1,164 @@
+package dedup
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// O log é reescrito só com os IDs válidos quando passa de fileCompactMinLines
+// linhas e do dobro dos IDs em memória (TTL vencido ou limite de entradas)
+const fileCompactMinLines = 1000
+
+// File mantém os IDs em memória e os grava em um log append-only,
+// recarregado na inicialização para sobreviver a restarts. O log é compactado
+// pelo sweeper e quando acumula linhas demais, então expirados e descartados
+// pelo limite de entradas não ficam no disco.
+type File struct {
+	*Memory
+	path  string
+	file  *os.File
+	lines int // linhas no log desde a última compactação
+	mu    sync.Mutex
+}
+
+// NewFile abre (ou cria) o log de deduplicação, recarrega os IDs ainda válidos
+// e já o reescreve sem os expirados
+func NewFile(opts Options) (*File, error) {
+	if opts.FilePath == "" {
+		return nil, fmt.Errorf("caminho do arquivo de deduplicação não informado")
+	}
+	mem := NewMemory(opts)
+	if err := loadLog(opts.FilePath, mem); err != nil {
+		return nil, err
+	}
+	f := &File{Memory: mem, path: opts.FilePath}
+	if err := f.compactLocked(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Cada linha do log: "<unix nano> <id>"
+func loadLog(path string, mem *Memory) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao ler arquivo de deduplicação: %w", err)
+	}
+	defer f.Close()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		ts, id, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		nanos, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			continue
+		}
+		markedAt := time.Unix(0, nanos)
+		if mem.expired(markedAt, now) {
+			continue
+		}
+		mem.markAt(id, markedAt)
+	}
+	return scanner.Err()
+}
+
+func (f *File) Mark(id string) error {
+	now := time.Now()
+	f.Memory.markAt(id, now)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := fmt.Fprintf(f.file, "%d %s\n", now.UnixNano(), id); err != nil {
+		return fmt.Errorf("erro ao gravar ID no arquivo de deduplicação: %w", err)
+	}
+	f.lines++
+	if live, _ := f.Memory.Len(); f.lines >= fileCompactMinLines && f.lines > 2*live {
+		return f.compactLocked()
+	}
+	return nil
+}
+
+// EvictExpired remove os IDs vencidos da memória e, se algum saiu, compacta o log
+func (f *File) EvictExpired() int {
+	removed := f.Memory.EvictExpired()
+	if removed > 0 {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if err := f.compactLocked(); err != nil {
+			log.Printf("[dedup] %v", err)
+		}
+	}
+	return removed
+}
+
+// compactLocked reescreve o log com os IDs válidos, do mais antigo ao mais novo,
+// num arquivo temporário que substitui o atual; chamado com mu travado
+func (f *File) compactLocked() error {
+	entries := f.Memory.live()
+	tmpPath := f.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("erro ao compactar arquivo de deduplicação: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	for _, e := range entries {
+		fmt.Fprintf(w, "%d %s\n", e.markedAt.UnixNano(), e.id)
+	}
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, f.path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("erro ao compactar arquivo de deduplicação: %w", err)
+	}
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir arquivo de deduplicação: %w", err)
+	}
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.file = file
+	f.lines = len(entries)
+	return nil
+}
+
+// Clear esvazia a memória e trunca o log, para o purge sobreviver a restarts
+func (f *File) Clear() error {
+	f.Memory.Clear()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.file.Truncate(0); err != nil {
+		return fmt.Errorf("erro ao truncar arquivo de deduplicação: %w", err)
+	}
+	f.lines = 0
+	return nil
+}
+
+func (f *File) Close() error {
+	f.Memory.Close()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,126 @@
+package dedup
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestFile(t *testing.T, opts Options) *File {
+	t.Helper()
+	if opts.FilePath == "" {
+		opts.FilePath = filepath.Join(t.TempDir(), "dedup.log")
+	}
+	f, err := NewFile(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func logLines(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	n := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		n++
+	}
+	return n
+}
+
+func TestFileCompactsAfterTTLExpiry(t *testing.T) {
+	f := newTestFile(t, Options{TTL: 50 * time.Millisecond})
+	for i := 0; i < 100; i++ {
+		if err := f.Mark(fmt.Sprintf("old-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(80 * time.Millisecond)
+	f.Mark("fresh")
+	if n := logLines(t, f.path); n != 101 {
+		t.Fatalf("log lines before the sweep = %d, want 101", n)
+	}
+
+	if removed := f.EvictExpired(); removed != 100 {
+		t.Fatalf("evicted %d, want 100", removed)
+	}
+	if n := logLines(t, f.path); n != 1 {
+		t.Fatalf("log lines after the sweep = %d, want only the fresh ID", n)
+	}
+	// O log compactado continua recebendo novas marcações
+	f.Mark("after")
+	if n := logLines(t, f.path); n != 2 {
+		t.Fatalf("log lines after a new mark = %d, want 2", n)
+	}
+}
+
+func TestFileCompactsEvictedEntries(t *testing.T) {
+	f := newTestFile(t, Options{MaxEntries: 10})
+	const marks = 5000
+	for i := 0; i < marks; i++ {
+		if err := f.Mark(fmt.Sprintf("id-%05d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Limite de 10 IDs: o log não cresce com os descartados
+	if n := logLines(t, f.path); n >= fileCompactMinLines+10 {
+		t.Fatalf("log lines = %d after %d marks with a cap of 10, want it compacted", n, marks)
+	}
+
+	// A recarga mantém exatamente os 10 IDs mais novos
+	f.Close()
+	reloaded := newTestFile(t, Options{MaxEntries: 10, FilePath: f.path})
+	if n, _ := reloaded.Len(); n != 10 {
+		t.Fatalf("reloaded %d IDs, want 10", n)
+	}
+	for _, id := range []string{"id-04990", "id-04999"} {
+		if seen, _ := reloaded.Seen(id); !seen {
+			t.Fatalf("%s lost across the restart", id)
+		}
+	}
+	if seen, _ := reloaded.Seen("id-04989"); seen {
+		t.Fatal("evicted ID came back after the restart")
+	}
+	if n := logLines(t, f.path); n != 10 {
+		t.Fatalf("log lines after reload = %d, want 10", n)
+	}
+}
+
+func TestFileRestartDropsExpiredIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.log")
+	first := newTestFile(t, Options{TTL: 30 * time.Millisecond, FilePath: path})
+	first.Mark("a")
+	first.Mark("b")
+	first.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	second := newTestFile(t, Options{TTL: 30 * time.Millisecond, FilePath: path})
+	if n, _ := second.Len(); n != 0 {
+		t.Fatalf("reloaded %d expired IDs, want 0", n)
+	}
+	if n := logLines(t, path); n != 0 {
+		t.Fatalf("log lines after restart = %d, want the expired IDs rewritten away", n)
+	}
+}
+
+func TestFileClearEmptiesLog(t *testing.T) {
+	f := newTestFile(t, Options{})
+	f.Mark("a")
+	f.Mark("b")
+	if err := f.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	f.Mark("c")
+	if n := logLines(t, f.path); n != 1 {
+		t.Fatalf("log lines after Clear and one mark = %d, want 1", n)
+	}
+}
@@ -0,0 +1,171 @@
+package dedup
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Memory mantém os IDs em memória, por instância
+type Memory struct {
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // ordem de inserção, mais antigo na frente
+	mu         sync.RWMutex
+
+	stopSweeper chan struct{} // fechado no Close quando o sweeper roda
+	closeOnce   sync.Once
+}
+
+type memoryEntry struct {
+	id       string
+	markedAt time.Time
+}
+
+// NewMemory cria um Deduper em memória
+func NewMemory(opts Options) *Memory {
+	return &Memory{
+		ttl:        opts.TTL,
+		maxEntries: opts.MaxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func (m *Memory) Seen(id string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	el, ok := m.entries[id]
+	if !ok {
+		return false, nil
+	}
+	return !m.expired(el.Value.(*memoryEntry).markedAt, time.Now()), nil
+}
+
+func (m *Memory) Mark(id string) error {
+	m.markAt(id, time.Now())
+	return nil
+}
+
+// markAt registra o ID com o instante informado (usado também na carga do backend file)
+func (m *Memory) markAt(id string, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.entries[id]; ok {
+		el.Value.(*memoryEntry).markedAt = at
+		m.order.MoveToBack(el)
+	} else {
+		m.entries[id] = m.order.PushBack(&memoryEntry{id: id, markedAt: at})
+	}
+	if m.maxEntries > 0 {
+		for m.order.Len() > m.maxEntries {
+			m.removeElement(m.order.Front())
+		}
+	}
+}
+
+func (m *Memory) Len() (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.entries), nil
+}
+
+func (m *Memory) Clear() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = make(map[string]*list.Element)
+	m.order.Init()
+	return nil
+}
+
+func (m *Memory) Close() error {
+	m.closeOnce.Do(func() {
+		if m.stopSweeper != nil {
+			close(m.stopSweeper)
+		}
+	})
+	return nil
+}
+
+// StartSweeper remove periodicamente os IDs expirados até o Close; sem TTL não há o que varrer
+func (m *Memory) StartSweeper(interval time.Duration) {
+	m.startSweeper(interval, m.EvictExpired)
+}
+
+// startSweeper roda evict a cada intervalo; backends persistentes passam o
+// próprio EvictExpired para limpar também o que está gravado
+func (m *Memory) startSweeper(interval time.Duration, evict func() int) {
+	if m.ttl <= 0 || interval <= 0 || m.stopSweeper != nil {
+		return
+	}
+	m.stopSweeper = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stopSweeper:
+				return
+			case <-ticker.C:
+				evict()
+			}
+		}
+	}()
+}
+
+func (m *Memory) expired(markedAt, now time.Time) bool {
+	return m.ttl > 0 && now.Sub(markedAt) > m.ttl
+}
+
+func (m *Memory) removeElement(el *list.Element) {
+	m.order.Remove(el)
+	delete(m.entries, el.Value.(*memoryEntry).id)
+}
+
+// EvictExpired remove os IDs cujo TTL já venceu e retorna quantos foram removidos
+func (m *Memory) EvictExpired() int {
+	if m.ttl <= 0 {
+		return 0
+	}
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := 0
+	for el := m.order.Front(); el != nil; {
+		next := el.Next()
+		if m.expired(el.Value.(*memoryEntry).markedAt, now) {
+			m.removeElement(el)
+			removed++
+		}
+		el = next
+	}
+	return removed
+}
+
+// Snapshot copia os IDs registrados e o instante em que foram marcados
+func (m *Memory) Snapshot() map[string]time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	snapshot := make(map[string]time.Time, len(m.entries))
+	for id, el := range m.entries {
+		snapshot[id] = el.Value.(*memoryEntry).markedAt
+	}
+	return snapshot
+}
+
+// live copia os IDs ainda válidos do mais antigo ao mais novo, a ordem em que
+// o limite de entradas os descarta
+func (m *Memory) live() []memoryEntry {
+	now := time.Now()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := make([]memoryEntry, 0, len(m.entries))
+	for el := m.order.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*memoryEntry)
+		if !m.expired(e.markedAt, now) {
+			entries = append(entries, *e)
+		}
+	}
+	return entries
+}
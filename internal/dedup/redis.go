@@ -0,0 +1,187 @@
+package dedup
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Redis compartilha os IDs entre instâncias usando um sorted set
+// (score = instante em ms), o que dá a mesma semântica de TTL e limite
+// de tamanho dos outros backends: expirados e excedentes saem pelo score.
+type Redis struct {
+	addr       string
+	key        string
+	ttl        time.Duration
+	maxEntries int
+	conn       net.Conn
+	reader     *bufio.Reader
+	mu         sync.Mutex
+}
+
+// NewRedis conecta ao Redis informado em opts.RedisAddr
+func NewRedis(opts Options) (*Redis, error) {
+	if opts.RedisAddr == "" {
+		return nil, fmt.Errorf("endereço do Redis não informado")
+	}
+	key := opts.RedisKey
+	if key == "" {
+		key = "rinha:dedup"
+	}
+	r := &Redis{addr: opts.RedisAddr, key: key, ttl: opts.TTL, maxEntries: opts.MaxEntries}
+	if err := r.connect(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Redis) connect() error {
+	conn, err := net.DialTimeout("tcp", r.addr, 500*time.Millisecond)
+	if err != nil {
+		return fmt.Errorf("erro ao conectar no Redis: %w", err)
+	}
+	r.conn = conn
+	r.reader = bufio.NewReader(conn)
+	return nil
+}
+
+func (r *Redis) Seen(id string) (bool, error) {
+	reply, err := r.do([]string{"ZSCORE", r.key, id})
+	if err != nil {
+		return false, err
+	}
+	if reply[0] == nil {
+		return false, nil
+	}
+	ms, err := strconv.ParseInt(*reply[0], 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("score inválido no Redis: %w", err)
+	}
+	return r.ttl <= 0 || time.Since(time.UnixMilli(ms)) <= r.ttl, nil
+}
+
+func (r *Redis) Mark(id string) error {
+	now := time.Now()
+	cmds := [][]string{{"ZADD", r.key, strconv.FormatInt(now.UnixMilli(), 10), id}}
+	if r.ttl > 0 {
+		cutoff := now.Add(-r.ttl).UnixMilli()
+		cmds = append(cmds, []string{"ZREMRANGEBYSCORE", r.key, "-inf", "(" + strconv.FormatInt(cutoff, 10)})
+	}
+	if r.maxEntries > 0 {
+		cmds = append(cmds, []string{"ZREMRANGEBYRANK", r.key, "0", strconv.Itoa(-r.maxEntries - 1)})
+	}
+	_, err := r.do(cmds...)
+	return err
+}
+
+func (r *Redis) Len() (int, error) {
+	reply, err := r.do([]string{"ZCARD", r.key})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(*reply[0])
+}
+
+func (r *Redis) Clear() error {
+	_, err := r.do([]string{"DEL", r.key})
+	return err
+}
+
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.Close()
+}
+
+// do envia os comandos em pipeline e retorna a resposta de cada um
+// (nil para bulk string nula); em caso de erro a conexão é descartada
+// e refeita na próxima chamada
+func (r *Redis) do(cmds ...[]string) ([]*string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		if err := r.connect(); err != nil {
+			return nil, err
+		}
+	}
+	replies, err := r.roundTrip(cmds)
+	if err != nil {
+		r.conn.Close()
+		r.conn = nil
+		return nil, fmt.Errorf("erro no Redis: %w", err)
+	}
+	return replies, nil
+}
+
+func (r *Redis) roundTrip(cmds [][]string) ([]*string, error) {
+	r.conn.SetDeadline(time.Now().Add(500 * time.Millisecond))
+
+	var buf []byte
+	for _, args := range cmds {
+		buf = append(buf, '*')
+		buf = strconv.AppendInt(buf, int64(len(args)), 10)
+		buf = append(buf, '\r', '\n')
+		for _, arg := range args {
+			buf = append(buf, '$')
+			buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+			buf = append(buf, '\r', '\n')
+			buf = append(buf, arg...)
+			buf = append(buf, '\r', '\n')
+		}
+	}
+	if _, err := r.conn.Write(buf); err != nil {
+		return nil, err
+	}
+
+	replies := make([]*string, 0, len(cmds))
+	for range cmds {
+		reply, err := r.readReply()
+		if err != nil {
+			return nil, err
+		}
+		replies = append(replies, reply)
+	}
+	return replies, nil
+}
+
+// readReply lê uma resposta RESP simples (status, erro, inteiro ou bulk string)
+func (r *Redis) readReply() (*string, error) {
+	line, err := r.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, fmt.Errorf("resposta RESP inválida: %q", line)
+	}
+	payload := line[1 : len(line)-2]
+	switch line[0] {
+	case '+', ':':
+		return &payload, nil
+	case '-':
+		return nil, fmt.Errorf("%s", payload)
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r.reader, data); err != nil {
+			return nil, err
+		}
+		value := string(data[:size])
+		return &value, nil
+	default:
+		return nil, fmt.Errorf("tipo RESP não suportado: %q", line[0])
+	}
+}
@@ -0,0 +1,56 @@
+package keys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// GenerateKey cria um par ed25519 com as duas metades em base64, no formato do arquivo de chaves.
+func GenerateKey(kid string) (KeyConfig, error) {
+	if kid == "" {
+		return KeyConfig{}, fmt.Errorf("kid vazio")
+	}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return KeyConfig{}, fmt.Errorf("falha ao gerar chave para kid %s: %w", kid, err)
+	}
+	return KeyConfig{
+		KID:        kid,
+		PublicKey:  base64.StdEncoding.EncodeToString(pub),
+		PrivateKey: base64.StdEncoding.EncodeToString(priv),
+	}, nil
+}
+
+// WriteKeysToFile grava as chaves no arquivo (permissão 0600, contém chaves
+// privadas). Escreve num temporário e renomeia, para não deixar o arquivo pela metade.
+func WriteKeysToFile(path string, configs []KeyConfig) error {
+	data, err := json.MarshalIndent(keysFile{Keys: configs}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("falha ao codificar JSON de chaves: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".keys-*.json")
+	if err != nil {
+		return fmt.Errorf("falha ao criar arquivo de chaves: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("falha ao gravar arquivo de chaves: %w", err)
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("falha ao gravar arquivo de chaves: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("falha ao gravar arquivo de chaves: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("falha ao gravar arquivo de chaves: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package keys
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// JWK de uma chave pública Ed25519 (RFC 8037)
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	Kid string `json:"kid"`
+	X   string `json:"x"`
+}
+
+// JWKS é o documento {"keys":[...]}
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKSHandler serve as chaves públicas do KeyStore como JWKS, ordenadas por kid.
+// Um KeyStore nil serve a lista vazia.
+func JWKSHandler(ks *KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc := JWKS{Keys: []JWK{}}
+		if ks != nil {
+			for kid, pub := range ks.PublicKeys {
+				doc.Keys = append(doc.Keys, JWK{
+					Kty: "OKP",
+					Crv: "Ed25519",
+					Kid: kid,
+					X:   base64.RawURLEncoding.EncodeToString(pub),
+				})
+			}
+			sort.Slice(doc.Keys, func(i, j int) bool { return doc.Keys[i].Kid < doc.Keys[j].Kid })
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(doc)
+	}
+}
@@ -2,12 +2,17 @@ package keys
 
 import (
 	"crypto/ed25519"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"os"
+	"strings"
 )
 
+// KeyConfig é uma chave do arquivo; cada metade pode vir em base64 dos bytes
+// ed25519 crus ou em PEM (PKIX para a pública, PKCS#8 para a privada).
 type KeyConfig struct {
 	KID        string `json:"kid"`
 	PublicKey  string `json:"publicKey"`
@@ -19,37 +24,125 @@ type KeyStore struct {
 	PrivateKeys map[string]ed25519.PrivateKey
 }
 
-// LoadKeysFromFile carrega as chaves de um arquivo JSON.
-func LoadKeysFromFile(path string) (*KeyStore, error) {
+// Formato do arquivo de chaves
+type keysFile struct {
+	Keys []KeyConfig `json:"keys"`
+}
+
+// ReadKeyConfigs lê as entradas do arquivo de chaves sem decodificá-las.
+func ReadKeyConfigs(path string) ([]KeyConfig, error) {
 	file, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("falha ao ler arquivo de chaves: %w", err)
 	}
-
-	var config struct {
-		Keys []KeyConfig `json:"keys"`
-	}
+	var config keysFile
 	if err := json.Unmarshal(file, &config); err != nil {
 		return nil, fmt.Errorf("falha ao decodificar JSON de chaves: %w", err)
 	}
+	return config.Keys, nil
+}
+
+// LoadKeysFromFile carrega as chaves de um arquivo JSON.
+func LoadKeysFromFile(path string) (*KeyStore, error) {
+	configs, err := ReadKeyConfigs(path)
+	if err != nil {
+		return nil, err
+	}
 
 	keyStore := &KeyStore{
 		PublicKeys:  make(map[string]ed25519.PublicKey),
 		PrivateKeys: make(map[string]ed25519.PrivateKey),
 	}
 
-	for _, keyConf := range config.Keys {
-		pub, err := base64.StdEncoding.DecodeString(keyConf.PublicKey)
+	for _, keyConf := range configs {
+		pub, err := decodePublicKey(keyConf.PublicKey)
 		if err != nil {
 			return nil, fmt.Errorf("falha ao decodificar chave pública para kid %s: %w", keyConf.KID, err)
 		}
-		priv, err := base64.StdEncoding.DecodeString(keyConf.PrivateKey)
+		priv, err := decodePrivateKey(keyConf.PrivateKey)
 		if err != nil {
 			return nil, fmt.Errorf("falha ao decodificar chave privada para kid %s: %w", keyConf.KID, err)
 		}
-		keyStore.PublicKeys[keyConf.KID] = ed25519.PublicKey(pub)
-		keyStore.PrivateKeys[keyConf.KID] = ed25519.PrivateKey(priv)
+		keyStore.PublicKeys[keyConf.KID] = pub
+		keyStore.PrivateKeys[keyConf.KID] = priv
 	}
 
 	return keyStore, nil
 }
+
+// isPEM detecta o formato pelo prefixo "-----BEGIN"
+func isPEM(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), "-----BEGIN")
+}
+
+// decodePEMBlock retorna os bytes DER do primeiro bloco PEM
+func decodePEMBlock(s string) ([]byte, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(s)))
+	if block == nil {
+		return nil, fmt.Errorf("bloco PEM inválido")
+	}
+	return block.Bytes, nil
+}
+
+// decodePublicKey aceita base64 dos bytes crus ou PEM PKIX
+func decodePublicKey(s string) (ed25519.PublicKey, error) {
+	if !isPEM(s) {
+		raw, err := base64.StdEncoding.DecodeString(s)
+		return ed25519.PublicKey(raw), err
+	}
+	der, err := decodePEMBlock(s)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("chave PEM não é ed25519: %T", key)
+	}
+	return pub, nil
+}
+
+// decodePrivateKey aceita base64 dos bytes crus ou PEM PKCS#8
+func decodePrivateKey(s string) (ed25519.PrivateKey, error) {
+	if !isPEM(s) {
+		raw, err := base64.StdEncoding.DecodeString(s)
+		return ed25519.PrivateKey(raw), err
+	}
+	der, err := decodePEMBlock(s)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("chave PEM não é ed25519: %T", key)
+	}
+	return priv, nil
+}
+
+// Sign assina a mensagem com a chave privada do kid.
+func (ks *KeyStore) Sign(kid string, message []byte) ([]byte, error) {
+	priv, ok := ks.PrivateKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("chave privada não encontrada para kid %s", kid)
+	}
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("chave privada inválida para kid %s: %d bytes", kid, len(priv))
+	}
+	return ed25519.Sign(priv, message), nil
+}
+
+// Verify confere a assinatura com a chave pública do kid; kid ausente ou chave inválida não verificam.
+func (ks *KeyStore) Verify(kid string, message, sig []byte) bool {
+	pub, ok := ks.PublicKeys[kid]
+	if !ok || len(pub) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(pub, message, sig)
+}
@@ -0,0 +1,57 @@
+package keys
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadKeysFromFileFormats(t *testing.T) {
+	// Os dois fixtures trazem o mesmo par de chaves, em base64 cru e em PEM
+	raw, err := LoadKeysFromFile("testdata/keys_base64.json")
+	if err != nil {
+		t.Fatalf("base64 fixture: %v", err)
+	}
+	pemStore, err := LoadKeysFromFile("testdata/keys_pem.json")
+	if err != nil {
+		t.Fatalf("PEM fixture: %v", err)
+	}
+
+	if !bytes.Equal(raw.PublicKeys["test-key"], pemStore.PublicKeys["test-key"]) {
+		t.Fatal("public keys differ between the base64 and PEM fixtures")
+	}
+	if !bytes.Equal(raw.PrivateKeys["test-key"], pemStore.PrivateKeys["test-key"]) {
+		t.Fatal("private keys differ between the base64 and PEM fixtures")
+	}
+
+	// Assinado com a chave PEM, verificado com a base64
+	msg := []byte(`{"correlationId":"k-1","amount":1}`)
+	sig, err := pemStore.Sign("test-key", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !raw.Verify("test-key", msg, sig) {
+		t.Fatal("signature from the PEM key does not verify with the base64 key")
+	}
+}
+
+func TestLoadKeysFromFileRejectsBadPEM(t *testing.T) {
+	cases := map[string]struct{ content, want string }{
+		"truncated block": {`{"keys":[{"kid":"k","publicKey":"-----BEGIN PUBLIC KEY-----\nMCow","privateKey":""}]}`, "PEM inválido"},
+		"wrong key type":  {`{"keys":[{"kid":"k","publicKey":"-----BEGIN PUBLIC KEY-----\nMFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE1JcLHszN+VAR2yihoCyzsXAR9dRplzWzNSq5GyDzwZvnN7CqPcKUVkugu1aGPlh8Jxc3SzFN6IbRS/OTWCtjDw==\n-----END PUBLIC KEY-----\n","privateKey":""}]}`, "não é ed25519"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.json")
+			if err := os.WriteFile(path, []byte(tc.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadKeysFromFile(path)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("err = %v, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+}
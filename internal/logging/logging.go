@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// Level representa o nível mínimo de log emitido
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+// Nível atual, lido de LOG_LEVEL (padrão: info)
+var level = ParseLevel(os.Getenv("LOG_LEVEL"))
+
+// ParseLevel converte o nome do nível, usando Info para valores desconhecidos
+func ParseLevel(name string) Level {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return Debug
+	case "warn", "warning":
+		return Warn
+	case "error":
+		return Error
+	default:
+		return Info
+	}
+}
+
+// SetLevel altera o nível mínimo de log
+func SetLevel(l Level) {
+	level = l
+}
+
+// Enabled indica se mensagens do nível informado devem ser emitidas
+func Enabled(l Level) bool {
+	return l >= level
+}
+
+// Debugf registra uma mensagem apenas quando o nível debug está ativo
+func Debugf(format string, args ...interface{}) {
+	if Enabled(Debug) {
+		log.Printf("[debug] "+format, args...)
+	}
+}
+
+// Infof registra uma mensagem de nível info
+func Infof(format string, args ...interface{}) {
+	if Enabled(Info) {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,55 @@
+package resolver
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/lucas-de-lima/rinha-de-backend-2025/internal/keys"
+)
+
+// ErrKeyNotFound indica que o JWKS não tem o kid pedido
+var ErrKeyNotFound = errors.New("kid não encontrado no JWKS")
+
+// NewHTTPKeySource busca as chaves num documento JWKS (ver keys.JWKSHandler).
+// Cada chamada faz um GET; o cache fica a cargo do CachingKeyResolver.
+func NewHTTPKeySource(jwksURL string, client *http.Client) KeySource {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(kid string) (ed25519.PublicKey, error) {
+		resp, err := client.Get(jwksURL)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao buscar JWKS %s: %w", jwksURL, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("falha ao buscar JWKS %s: status %d", jwksURL, resp.StatusCode)
+		}
+
+		var doc keys.JWKS
+		if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+			return nil, fmt.Errorf("JWKS malformado em %s: %w", jwksURL, err)
+		}
+		for _, jwk := range doc.Keys {
+			if jwk.Kid != kid {
+				continue
+			}
+			if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" {
+				return nil, fmt.Errorf("JWKS malformado em %s: kid %s não é Ed25519 (kty=%s crv=%s)", jwksURL, kid, jwk.Kty, jwk.Crv)
+			}
+			x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+			if err != nil {
+				return nil, fmt.Errorf("JWKS malformado em %s: x do kid %s: %w", jwksURL, kid, err)
+			}
+			if len(x) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("JWKS malformado em %s: x do kid %s com %d bytes", jwksURL, kid, len(x))
+			}
+			return ed25519.PublicKey(x), nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+	}
+}
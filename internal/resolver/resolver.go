@@ -0,0 +1,192 @@
+// Package resolver resolve chaves públicas ed25519 por kid, com cache por TTL
+// na frente de uma fonte de chaves (arquivo, JWKS via HTTP, ...).
+package resolver
+
+import (
+	"crypto/ed25519"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxEntries limita os kids em cache: kids arbitrários vindos de fora não esgotam a memória
+const DefaultMaxEntries = 1024
+
+// KeySource busca a chave pública de um kid na origem
+type KeySource func(kid string) (ed25519.PublicKey, error)
+
+// Chave em cache com sua expiração; key e expires mudam só com o lock de escrita
+type cacheEntry struct {
+	key      ed25519.PublicKey
+	expires  time.Time
+	lastUsed atomic.Int64 // último acesso (UnixNano), atualizado também no caminho de leitura
+}
+
+// CachingKeyResolver guarda as chaves resolvidas por cacheTTL
+type CachingKeyResolver struct {
+	keySource  KeySource
+	cacheTTL   time.Duration
+	maxEntries int // 0 = sem limite
+
+	mu    sync.RWMutex
+	cache map[string]*cacheEntry
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// NewCachingKeyResolver cria o resolver sobre keySource
+func NewCachingKeyResolver(keySource KeySource, cacheTTL time.Duration) *CachingKeyResolver {
+	return &CachingKeyResolver{
+		keySource:  keySource,
+		cacheTTL:   cacheTTL,
+		maxEntries: DefaultMaxEntries,
+		cache:      make(map[string]*cacheEntry),
+	}
+}
+
+// WithMaxEntries troca o limite de kids em cache (0 = sem limite)
+func (r *CachingKeyResolver) WithMaxEntries(maxEntries int) *CachingKeyResolver {
+	r.maxEntries = maxEntries
+	return r
+}
+
+// ResolverFunc retorna a chave do kid: do cache se ainda válida, senão da
+// origem (com o lock de escrita, rechecando o cache antes de buscar)
+func (r *CachingKeyResolver) ResolverFunc(kid string) (ed25519.PublicKey, error) {
+	r.mu.RLock()
+	if key, ok := r.validLocked(kid); ok {
+		r.mu.RUnlock()
+		r.hits.Add(1)
+		return key, nil
+	}
+	r.mu.RUnlock()
+	r.misses.Add(1)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if key, ok := r.validLocked(kid); ok {
+		return key, nil
+	}
+	key, err := r.keySource(kid)
+	if err != nil {
+		return nil, err
+	}
+	if _, cached := r.cache[kid]; !cached && r.maxEntries > 0 && len(r.cache) >= r.maxEntries {
+		r.evictLRULocked()
+	}
+	r.storeLocked(kid, key).lastUsed.Store(time.Now().UnixNano())
+	return key, nil
+}
+
+// validLocked retorna a chave se estiver em cache e não expirada, marcando o acesso.
+// Basta o lock de leitura.
+func (r *CachingKeyResolver) validLocked(kid string) (ed25519.PublicKey, bool) {
+	entry, ok := r.cache[kid]
+	if !ok {
+		return nil, false
+	}
+	now := time.Now()
+	entry.lastUsed.Store(now.UnixNano())
+	if !now.Before(entry.expires) {
+		return nil, false
+	}
+	return entry.key, true
+}
+
+// evictLRULocked remove o kid usado há mais tempo. Varre o cache inteiro, mas
+// só roda em miss com o cache cheio, que já paga a busca na origem.
+func (r *CachingKeyResolver) evictLRULocked() {
+	var oldest string
+	var oldestAt int64
+	first := true
+	for kid, entry := range r.cache {
+		if at := entry.lastUsed.Load(); first || at < oldestAt {
+			oldest, oldestAt, first = kid, at, false
+		}
+	}
+	if !first {
+		delete(r.cache, oldest)
+	}
+}
+
+// Invalidate descarta o kid do cache; o próximo ResolverFunc busca na origem
+// (ex.: após rotacionar uma chave comprometida)
+func (r *CachingKeyResolver) Invalidate(kid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cache, kid)
+}
+
+// Stats retorna acertos e falhas do cache e quantos kids estão nele
+func (r *CachingKeyResolver) Stats() (hits, misses, entries int) {
+	r.mu.RLock()
+	entries = len(r.cache)
+	r.mu.RUnlock()
+	return int(r.hits.Load()), int(r.misses.Load()), entries
+}
+
+// storeLocked grava a chave com expiração renovada, reaproveitando a entrada existente
+func (r *CachingKeyResolver) storeLocked(kid string, key ed25519.PublicKey) *cacheEntry {
+	entry, ok := r.cache[kid]
+	if !ok {
+		entry = &cacheEntry{}
+		r.cache[kid] = entry
+	}
+	entry.key = key
+	entry.expires = time.Now().Add(r.cacheTTL)
+	return entry
+}
+
+// Fração do TTL a partir da qual o refresher renova a chave
+const refreshAt = 0.8
+
+// StartRefresher renova em segundo plano as chaves que passaram de 80% do TTL,
+// fora do caminho das requisições: enquanto isso a chave atual segue servida.
+// Chaves já expiradas ficam para a busca sob demanda. Para quando stop fechar.
+func (r *CachingKeyResolver) StartRefresher(stop <-chan struct{}) {
+	interval := r.cacheTTL / 10
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				r.refreshDue()
+			}
+		}
+	}()
+}
+
+// refreshDue busca na origem, sem lock, as chaves perto de expirar
+func (r *CachingKeyResolver) refreshDue() {
+	now := time.Now()
+	margin := time.Duration(float64(r.cacheTTL) * (1 - refreshAt))
+	var due []string
+	r.mu.RLock()
+	for kid, entry := range r.cache {
+		if now.Before(entry.expires) && !now.Before(entry.expires.Add(-margin)) {
+			due = append(due, kid)
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, kid := range due {
+		key, err := r.keySource(kid)
+		if err != nil {
+			// Mantém a chave atual até expirar; a busca sob demanda tenta de novo
+			continue
+		}
+		r.mu.Lock()
+		// Não recoloca um kid despejado enquanto buscava
+		if _, ok := r.cache[kid]; ok {
+			r.storeLocked(kid, key)
+		}
+		r.mu.Unlock()
+	}
+}
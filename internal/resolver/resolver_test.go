@@ -0,0 +1,147 @@
+package resolver
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingSource devolve uma chave por kid e conta as buscas na origem
+type countingSource struct {
+	mu      sync.Mutex
+	calls   map[string]int
+	version byte
+	fail    bool
+}
+
+func (s *countingSource) fetch(kid string) (ed25519.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return nil, errors.New("source down")
+	}
+	if s.calls == nil {
+		s.calls = make(map[string]int)
+	}
+	s.calls[kid]++
+	key := make(ed25519.PublicKey, ed25519.PublicKeySize)
+	copy(key, kid)
+	key[ed25519.PublicKeySize-1] = s.version
+	return key, nil
+}
+
+func (s *countingSource) count(kid string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[kid]
+}
+
+func resolve(t *testing.T, r *CachingKeyResolver, kid string) ed25519.PublicKey {
+	t.Helper()
+	key, err := r.ResolverFunc(kid)
+	if err != nil {
+		t.Fatalf("resolve %s: %v", kid, err)
+	}
+	return key
+}
+
+func TestResolverEvictsFirstKidBeyondMaxEntries(t *testing.T) {
+	const max = 4
+	src := &countingSource{}
+	r := NewCachingKeyResolver(src.fetch, time.Minute).WithMaxEntries(max)
+
+	for i := 0; i <= max; i++ {
+		resolve(t, r, fmt.Sprintf("kid-%d", i))
+		time.Sleep(time.Millisecond) // ordem de acesso inequívoca
+	}
+	if _, _, entries := r.Stats(); entries != max {
+		t.Fatalf("entries = %d, want %d", entries, max)
+	}
+
+	// kid-0 saiu do cache: resolvê-lo volta à origem; kid-4 continua em cache
+	resolve(t, r, "kid-4")
+	if n := src.count("kid-4"); n != 1 {
+		t.Fatalf("kid-4 fetched %d times, want 1 (cached)", n)
+	}
+	resolve(t, r, "kid-0")
+	if n := src.count("kid-0"); n != 2 {
+		t.Fatalf("kid-0 fetched %d times, want 2 (evicted)", n)
+	}
+}
+
+func TestResolverEvictsLeastRecentlyResolved(t *testing.T) {
+	src := &countingSource{}
+	r := NewCachingKeyResolver(src.fetch, time.Minute).WithMaxEntries(3)
+	for _, kid := range []string{"a", "b", "c"} {
+		resolve(t, r, kid)
+		time.Sleep(time.Millisecond)
+	}
+	// Uso recente protege "a": o menos usado passa a ser "b"
+	resolve(t, r, "a")
+	time.Sleep(time.Millisecond)
+	resolve(t, r, "d")
+
+	// Em ordem: resolver "b" por último, pois ele volta ao cache despejando outro
+	for _, tc := range []struct {
+		kid  string
+		want int
+	}{{"a", 1}, {"c", 1}, {"d", 1}, {"b", 2}} {
+		resolve(t, r, tc.kid)
+		if n := src.count(tc.kid); n != tc.want {
+			t.Fatalf("%s fetched %d times, want %d", tc.kid, n, tc.want)
+		}
+	}
+}
+
+func TestResolverRefreshesBeforeExpiry(t *testing.T) {
+	const ttl = 100 * time.Millisecond
+	src := &countingSource{version: 1}
+	r := NewCachingKeyResolver(src.fetch, ttl)
+	first := resolve(t, r, "rotating")
+
+	// Ainda longe do fim do TTL: nada a renovar
+	r.refreshDue()
+	if n := src.count("rotating"); n != 1 {
+		t.Fatalf("fetched %d times before the refresh window, want 1", n)
+	}
+
+	// Nova versão na origem; depois de 80% do TTL o refresher a traz
+	src.mu.Lock()
+	src.version = 2
+	src.mu.Unlock()
+	time.Sleep(ttl * 85 / 100)
+	r.refreshDue()
+
+	_, missesBefore, _ := r.Stats()
+	got := resolve(t, r, "rotating")
+	if got[ed25519.PublicKeySize-1] != 2 || first[ed25519.PublicKeySize-1] != 1 {
+		t.Fatalf("key version = %d, want the refreshed 2", got[ed25519.PublicKeySize-1])
+	}
+	if _, misses, _ := r.Stats(); misses != missesBefore {
+		t.Fatal("refreshed key was fetched on the request path")
+	}
+
+	// Origem fora: o refresher mantém a chave atual até expirar
+	src.mu.Lock()
+	src.fail = true
+	src.mu.Unlock()
+	time.Sleep(ttl * 85 / 100)
+	r.refreshDue()
+	if key := resolve(t, r, "rotating"); key[ed25519.PublicKeySize-1] != 2 {
+		t.Fatal("failed refresh dropped the current key")
+	}
+}
+
+func TestResolverDoesNotCacheErrors(t *testing.T) {
+	src := &countingSource{fail: true}
+	r := NewCachingKeyResolver(src.fetch, time.Minute)
+	if _, err := r.ResolverFunc("missing"); err == nil {
+		t.Fatal("want the source error")
+	}
+	if _, _, entries := r.Stats(); entries != 0 {
+		t.Fatalf("entries = %d, want 0 after a failed fetch", entries)
+	}
+}